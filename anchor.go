@@ -0,0 +1,250 @@
+package godoc2md
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Anchor describes how a platform turns headings into anchors.
+type Anchor struct {
+	// Explicit is true if the platform supports explicit heading IDs: "## Heading {#id}". The anchor is
+	// then computed from the id the template gives the heading, otherwise from the heading's text.
+	Explicit bool
+	// Slug returns the anchor for s.
+	Slug func(s string) string
+	// Dedup returns the anchor for the nth (starting at 1) duplicate of anchor.
+	Dedup func(anchor string, n int) string
+}
+
+// Anchors holds all anchor styles, callers may add their own. The default style is taken from the flavor.
+var Anchors = map[string]*Anchor{
+	"godoc":     {Explicit: true, Slug: godocSlug, Dedup: dashDedup},
+	"mmark":     {Explicit: true, Slug: mmarkSlug, Dedup: dashDedup},
+	"github":    {Slug: githubSlug, Dedup: dashDedup},
+	"gitlab":    {Slug: gitlabSlug, Dedup: dashDedup},
+	"bitbucket": {Slug: bitbucketSlug, Dedup: dashDedup},
+}
+
+// anchor returns the anchor style configured in c.
+func (c *Config) anchor() (*Anchor, error) {
+	name := c.Anchors
+	if name == "" {
+		f, err := c.flavor()
+		if err != nil {
+			return nil, err
+		}
+		name = f.Anchors
+	}
+	a, ok := Anchors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown anchor style: %q", name)
+	}
+	return a, nil
+}
+
+// headingKey returns the key the heading text s gets in the generated markdown, this is the anchor
+// godoc uses, but non-ASCII letters and digits are kept.
+func headingKey(s string) string {
+	return "hdr-" + strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// godocSlug leaves the keys as is, as these are godoc compatible already.
+func godocSlug(s string) string { return s }
+
+// mmarkSlug mirrors the auto heading IDs of mmark: lowercase letters and digits, everything else
+// becomes a single dash.
+func mmarkSlug(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsNumber(r) {
+			dash = true
+			continue
+		}
+		if dash && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		dash = false
+		b.WriteRune(unicode.ToLower(r))
+	}
+	if b.Len() == 0 {
+		return "empty"
+	}
+	return b.String()
+}
+
+// githubSlug mirrors GitHub: lowercase, punctuation is removed and spaces become dashes.
+func githubSlug(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == ' ':
+			return '-'
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r):
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, strings.TrimSpace(s))
+}
+
+var dashesRx = regexp.MustCompile(`-+`)
+
+// gitlabSlug mirrors GitLab: like GitHub, but consecutive dashes are collapsed.
+func gitlabSlug(s string) string {
+	slug := dashesRx.ReplaceAllString(githubSlug(s), "-")
+	if slug == "" {
+		return "anchor"
+	}
+	return slug
+}
+
+// bitbucketSlug mirrors Bitbucket, which prefixes the anchors with "markdown-header-".
+func bitbucketSlug(s string) string {
+	return "markdown-header-" + dashesRx.ReplaceAllString(githubSlug(s), "-")
+}
+
+func dashDedup(anchor string, n int) string { return anchor + "-" + strconv.Itoa(n) }
+
+var (
+	headingIDRx = regexp.MustCompile(`\s+\{#([^}\s]+)\}\s*$`)
+	mdLinkRx    = regexp.MustCompile(`\[((?:\\.|[^\]\\])*)\]\([^)]*\)`)
+	localLinkRx = regexp.MustCompile(`\]\(#([^)\s]+)\)`)
+	clickLinkRx = regexp.MustCompile(`^(\s*click \S+ href )"#([^"\s]+)"`)
+	htmlTagRx   = regexp.MustCompile(`<[^>]*>`)
+	mdEscapeRx  = regexp.MustCompile(`\\(.)`)
+)
+
+// headingText returns the text of the heading in line as it would be rendered.
+func headingText(line string) string {
+	text := strings.TrimLeft(strings.TrimSpace(line), "#")
+	text = headingIDRx.ReplaceAllString(text, "")
+	text = mdLinkRx.ReplaceAllString(text, "$1")
+	text = htmlTagRx.ReplaceAllString(text, "")
+	text = strings.Replace(text, "`", "", -1)
+	text = mdEscapeRx.ReplaceAllString(text, "$1")
+	return strings.TrimSpace(text)
+}
+
+// occurrence records where a heading with a key is used in the document.
+type occurrence struct {
+	line   int
+	anchor string
+}
+
+// anchors rewrites the headings and local links in the generated markdown buf. The template and toMd
+// give each heading a key, "## Heading {#key}", and local links refer to that key. These keys are
+// turned into the anchors of style a. Duplicate anchors are made unique like the platform does.
+// A link is resolved to the first heading with its key after it, or else to the last one before it.
+func anchors(buf []byte, a *Anchor) []byte {
+	lines := strings.Split(string(buf), "\n")
+	keys := headingAnchors(lines, a)
+	rewriteLinks(lines, func(key string, i int) (string, bool) {
+		anchor, ok := keys.resolve(key, i)
+		return "#" + anchor, ok
+	})
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// headingKeys holds where each heading key is used in a document, and the anchor it became.
+type headingKeys map[string][]occurrence
+
+// resolve returns the anchor of the heading that a link to key on line i refers to, see anchors.
+func (k headingKeys) resolve(key string, i int) (string, bool) {
+	occs, ok := k[key]
+	if !ok {
+		return "", false
+	}
+	for _, o := range occs {
+		if o.line >= i {
+			return o.anchor, true
+		}
+	}
+	return occs[len(occs)-1].anchor, true
+}
+
+// headingAnchors turns the keys of the headings in lines into anchors of style a, see anchors.
+func headingAnchors(lines []string, a *Anchor) headingKeys {
+	seen := map[string]int{}
+	keys := headingKeys{}
+
+	fence := ""
+	for i, line := range lines {
+		if fence = fenced(line, fence); fence != "" {
+			continue
+		}
+		if headingLevel(line, "") == 0 {
+			continue
+		}
+		key := ""
+		if m := headingIDRx.FindStringSubmatch(line); m != nil {
+			key = m[1]
+		}
+		if key == "" && a.Explicit {
+			continue
+		}
+
+		var anchor string
+		if a.Explicit {
+			anchor = a.Slug(key)
+		} else {
+			anchor = a.Slug(headingText(line))
+		}
+		if seen[anchor] > 0 {
+			base := anchor
+			for n := seen[base]; ; n++ {
+				if anchor = a.Dedup(base, n); seen[anchor] == 0 {
+					seen[base] = n + 1
+					break
+				}
+			}
+		}
+		seen[anchor]++
+
+		if key == "" {
+			continue
+		}
+		keys[key] = append(keys[key], occurrence{i, anchor})
+		if a.Explicit {
+			lines[i] = headingIDRx.ReplaceAllString(line, " {#"+anchor+"}")
+		} else {
+			lines[i] = headingIDRx.ReplaceAllString(line, "")
+		}
+	}
+	return keys
+}
+
+// rewriteLinks rewrites the local links in lines, "(#key)", to the target resolve returns for the key
+// and the line the link is on. Links that resolve doesn't know are left alone.
+func rewriteLinks(lines []string, resolve func(key string, i int) (string, bool)) {
+	fence, mermaid := "", false
+	for i, line := range lines {
+		open := fence == ""
+		if fence = fenced(line, fence); fence != "" {
+			if open {
+				mermaid = strings.HasPrefix(strings.TrimLeft(strings.TrimSpace(line), fence[:1]), "mermaid")
+				continue
+			}
+			// links of the nodes in Mermaid diagrams: click Name href "#key"
+			if m := clickLinkRx.FindStringSubmatch(line); mermaid && m != nil {
+				if target, ok := resolve(m[2], i); ok {
+					lines[i] = m[1] + `"` + target + `"` + line[len(m[0]):]
+				}
+			}
+			continue
+		}
+		lines[i] = localLinkRx.ReplaceAllStringFunc(line, func(link string) string {
+			key := localLinkRx.FindStringSubmatch(link)[1]
+			if target, ok := resolve(key, i); ok {
+				return "](" + target + ")"
+			}
+			return link
+		})
+	}
+}
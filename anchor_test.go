@@ -0,0 +1,91 @@
+package godoc2md
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSlugs(t *testing.T) {
+	tests := []struct {
+		slug func(string) string
+		in   string
+		exp  string
+	}{
+		{githubSlug, "func (\\*T) Method", "func-t-method"},
+		{githubSlug, "Überblick der API", "überblick-der-api"},
+		{githubSlug, "type Foo_Bar", "type-foo_bar"},
+		{gitlabSlug, "a -- b", "a-b"},
+		{mmarkSlug, "T.Method", "t-method"},
+		{mmarkSlug, "hdr-Größe", "hdr-größe"},
+		{bitbucketSlug, "Package files", "markdown-header-package-files"},
+		{headingKey, "Größe und Maße", "hdr-Größe_und_Maße"},
+	}
+	for _, tc := range tests {
+		if got := tc.slug(tc.in); got != tc.exp {
+			t.Errorf("slug of %q: expected %q, got %q", tc.in, tc.exp, got)
+		}
+	}
+}
+
+func TestAnchors(t *testing.T) {
+	doc := `* [Usage](#hdr-Usage)
+
+## type [T](https://example.org) {#T}
+* [Usage](#hdr-Usage)
+
+### Usage {#hdr-Usage}
+
+## func (\*T) [Usage](https://example.org) {#T.Usage}
+* [Usage](#hdr-Usage)
+
+### Usage {#hdr-Usage}
+
+` + "```" + `
+## not a heading {#T}
+` + "```" + `
+* [T](#T)
+`
+	tests := map[string]string{
+		"godoc": `* [Usage](#hdr-Usage)
+
+## type [T](https://example.org) {#T}
+* [Usage](#hdr-Usage)
+
+### Usage {#hdr-Usage}
+
+## func (\*T) [Usage](https://example.org) {#T.Usage}
+* [Usage](#hdr-Usage-1)
+
+### Usage {#hdr-Usage-1}
+
+` + "```" + `
+## not a heading {#T}
+` + "```" + `
+* [T](#T)
+`,
+		"github": `* [Usage](#usage)
+
+## type [T](https://example.org)
+* [Usage](#usage)
+
+### Usage
+
+## func (\*T) [Usage](https://example.org)
+* [Usage](#usage-1)
+
+### Usage
+
+` + "```" + `
+## not a heading {#T}
+` + "```" + `
+* [T](#type-t)
+`,
+	}
+	for style, exp := range tests {
+		got := string(anchors([]byte(doc), Anchors[style]))
+		if diff := cmp.Diff(exp, got); diff != "" {
+			t.Errorf("style %s: unexpected diff: %s", style, diff)
+		}
+	}
+}
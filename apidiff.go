@@ -0,0 +1,329 @@
+package godoc2md
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/printer"
+	"io"
+	"sort"
+	"strings"
+)
+
+// APIChange is a change to an exported symbol of a package.
+type APIChange struct {
+	Symbol     string // Symbol that changed: "func F", "method T.M", "type T", "field T.F", "const C", etc.
+	Change     string // What changed: "added", "removed" or a description.
+	Old, New   string // Old and new declaration, empty when added or removed.
+	Compatible bool   // Change is backwards compatible according to the Go 1 compatibility rules.
+}
+
+// apiSymbol is an exported symbol and its declaration, as printed.
+type apiSymbol struct {
+	decl string
+	key  string // compared instead of decl if not empty, e.g. signatures without parameter names
+	kind string // kind of a type: struct, interface or other
+	// for interfaces, the methods can only be added to if the interface can't be implemented outside
+	// of the package: it has unexported methods
+	sealed bool
+}
+
+// api loads the package in path with the same loader Transform uses and returns the declarations of
+// its exported symbols.
+func api(path string, config *Config) (map[string]apiSymbol, error) {
+	fs, pres := presentation(config)
+	info, err := load(fs, pres, path, config)
+	if err != nil {
+		return nil, err
+	}
+	if info.PDoc == nil {
+		return nil, fmt.Errorf("%s: no package", path)
+	}
+	syms := map[string]apiSymbol{}
+	print := func(node interface{}) string {
+		buf := &bytes.Buffer{}
+		(&printer.Config{Mode: printer.UseSpaces, Tabwidth: 4}).Fprint(buf, info.FSet, node)
+		return buf.String()
+	}
+	values := func(kind string, vs []*doc.Value) {
+		for _, v := range vs {
+			for _, spec := range v.Decl.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, n := range vs.Names {
+					if !n.IsExported() {
+						continue
+					}
+					decl := kind + " " + n.Name
+					if vs.Type != nil {
+						decl += " " + print(vs.Type)
+					}
+					if i < len(vs.Values) {
+						decl += " = " + print(vs.Values[i])
+					}
+					syms[kind+" "+n.Name] = apiSymbol{decl: decl}
+				}
+			}
+		}
+	}
+	funcs := func(fs []*doc.Func, recv string) {
+		for _, f := range fs {
+			decl := *f.Decl
+			decl.Doc, decl.Body = nil, nil
+			name := "func " + f.Name
+			if recv != "" {
+				name = "method " + recv + "." + f.Name
+			}
+			key := print(&ast.FuncDecl{Recv: unnamed(decl.Recv), Name: decl.Name, Type: &ast.FuncType{
+				TypeParams: unnamed(decl.Type.TypeParams), Params: unnamed(decl.Type.Params), Results: unnamed(decl.Type.Results)}})
+			syms[name] = apiSymbol{decl: print(&decl), key: key}
+		}
+	}
+
+	values("const", info.PDoc.Consts)
+	values("var", info.PDoc.Vars)
+	funcs(info.PDoc.Funcs, "")
+	for _, t := range info.PDoc.Types {
+		values("const", t.Consts)
+		values("var", t.Vars)
+		funcs(t.Funcs, "")
+		funcs(t.Methods, t.Name)
+
+		for _, spec := range t.Decl.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if ts.Name.Name != t.Name {
+				continue
+			}
+			// spec returns the declaration of the type with the type parameters tparams, and with
+			// only the kind of a struct or interface, their members are compared on their own.
+			spec := func(tparams *ast.FieldList) string {
+				s := *ts
+				s.Doc, s.Comment, s.TypeParams = nil, nil, tparams
+				switch ts.Type.(type) {
+				case *ast.StructType:
+					s.Type = ast.NewIdent("struct")
+				case *ast.InterfaceType:
+					s.Type = ast.NewIdent("interface")
+				}
+				return "type " + print(&s)
+			}
+			sym := apiSymbol{kind: "other", decl: spec(ts.TypeParams), key: spec(unnamed(ts.TypeParams))}
+			switch typ := ts.Type.(type) {
+			case *ast.StructType:
+				sym.kind = "struct"
+				for _, f := range typ.Fields.List {
+					names := fieldNames(f)
+					for _, n := range names {
+						if ast.IsExported(n) {
+							syms["field "+t.Name+"."+n] = apiSymbol{decl: n + " " + print(f.Type)}
+						}
+					}
+				}
+			case *ast.InterfaceType:
+				sym.kind = "interface"
+				sym.sealed = typ.Incomplete
+				for _, f := range typ.Methods.List {
+					for _, n := range fieldNames(f) {
+						if ast.IsExported(n) {
+							sym := apiSymbol{decl: n + strings.TrimPrefix(print(f.Type), "func")}
+							if ft, ok := f.Type.(*ast.FuncType); ok {
+								sym.key = n + print(&ast.FuncType{Params: unnamed(ft.Params), Results: unnamed(ft.Results)})
+							}
+							syms["interface method "+t.Name+"."+n] = sym
+						}
+					}
+				}
+			}
+			syms["type "+t.Name] = sym
+		}
+	}
+	return syms, nil
+}
+
+// unnamed returns the field list fl without the names of the fields, parameter and type parameter
+// names don't matter for compatibility.
+func unnamed(fl *ast.FieldList) *ast.FieldList {
+	if fl == nil {
+		return nil
+	}
+	u := &ast.FieldList{}
+	for _, f := range fl.List {
+		n := len(f.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			u.List = append(u.List, &ast.Field{Type: f.Type})
+		}
+	}
+	return u
+}
+
+// compareKey returns the string that is compared to see if s changed.
+func (s apiSymbol) compareKey() string {
+	if s.key != "" {
+		return s.key
+	}
+	return s.decl
+}
+
+// fieldNames returns the names of the field f, the type name for an embedded field.
+func fieldNames(f *ast.Field) []string {
+	if len(f.Names) > 0 {
+		names := make([]string, len(f.Names))
+		for i, n := range f.Names {
+			names[i] = n.Name
+		}
+		return names
+	}
+	t := f.Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	switch t := t.(type) {
+	case *ast.Ident:
+		return []string{t.Name}
+	case *ast.SelectorExpr:
+		return []string{t.Sel.Name}
+	}
+	return nil
+}
+
+// APIDiff compares the exported API of the packages in the directories oldPath and newPath, both are
+// loaded with config. The changes are sorted by symbol.
+func APIDiff(oldPath, newPath string, config *Config) ([]APIChange, error) {
+	old, err := api(oldPath, config)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := api(newPath, config)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []APIChange
+	for name, o := range old {
+		n, ok := cur[name]
+		if !ok {
+			if !ownerReported(name, cur) {
+				changes = append(changes, APIChange{Symbol: name, Change: "removed", Old: o.decl})
+			}
+			continue
+		}
+		if o.compareKey() == n.compareKey() && o.sealed == n.sealed {
+			continue
+		}
+		c := APIChange{Symbol: name, Change: "changed", Old: o.decl, New: n.decl}
+		switch {
+		case o.kind != n.kind:
+			c.Change = "changed from " + o.kind + " to " + n.kind
+		case o.compareKey() == n.compareKey() && n.sealed:
+			c.Change, c.Old, c.New = "now has unexported methods", "", ""
+		case o.compareKey() == n.compareKey():
+			c.Change, c.Old, c.New = "no longer has unexported methods", "", ""
+			c.Compatible = true
+		}
+		changes = append(changes, c)
+	}
+	for name, n := range cur {
+		if _, ok := old[name]; ok || ownerReported(name, old) {
+			continue
+		}
+		c := APIChange{Symbol: name, Change: "added", New: n.decl, Compatible: true}
+		if strings.HasPrefix(name, "interface method ") {
+			// adding a method to an interface breaks its implementations outside the package
+			c.Compatible = cur["type "+owner(name)].sealed
+		}
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Symbol < changes[j].Symbol })
+	return changes, nil
+}
+
+// owner returns the type name of a member symbol: "field T.F" returns T. It returns the empty string
+// for other symbols.
+func owner(name string) string {
+	if !strings.HasPrefix(name, "field ") && !strings.Contains(name, "method ") {
+		return ""
+	}
+	name = name[strings.LastIndex(name, " ")+1:]
+	return name[:strings.Index(name, ".")]
+}
+
+// ownerReported returns true if name is a member of a type that doesn't exist, or is of another kind, in
+// the other version of the API, syms. The change of the type is reported instead.
+func ownerReported(name string, syms map[string]apiSymbol) bool {
+	o := owner(name)
+	if o == "" {
+		return false
+	}
+	t, ok := syms["type "+o]
+	switch {
+	case !ok:
+		return true
+	case strings.HasPrefix(name, "field "):
+		return t.kind != "struct"
+	case strings.HasPrefix(name, "interface method "):
+		return t.kind != "interface"
+	}
+	return false
+}
+
+// APIReport writes a markdown report of the changes to w, title is used as the first heading. It
+// returns true if any of the changes is incompatible.
+func APIReport(w io.Writer, title string, changes []APIChange, config *Config) (bool, error) {
+	a, err := config.anchor()
+	if err != nil {
+		return false, err
+	}
+	var compatible, incompatible []APIChange
+	for _, c := range changes {
+		if c.Compatible {
+			compatible = append(compatible, c)
+		} else {
+			incompatible = append(incompatible, c)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "# %s\n\n", escapeMd(title, ctxHeading))
+	if len(changes) == 0 {
+		buf.WriteString("No changes to the exported API.\n")
+	} else {
+		fmt.Fprintf(buf, "%d incompatible and %d compatible changes.\n", len(incompatible), len(compatible))
+	}
+	section := func(heading, key string, changes []APIChange) {
+		if len(changes) == 0 {
+			return
+		}
+		fmt.Fprintf(buf, "\n## %s {#%s}\n\n", heading, key)
+		for _, c := range changes {
+			fmt.Fprintf(buf, "* %s: %s\n", codeSpan(c.Symbol), escapeMd(c.Change, ctxPara))
+			if c.Old == "" && c.New == "" {
+				continue
+			}
+			lines := []string{}
+			for _, l := range strings.Split(c.Old, "\n") {
+				if c.Old != "" {
+					lines = append(lines, "- "+l)
+				}
+			}
+			for _, l := range strings.Split(c.New, "\n") {
+				if c.New != "" {
+					lines = append(lines, "+ "+l)
+				}
+			}
+			fence := codeFence(lines)
+			fmt.Fprintf(buf, "\n  %s diff\n", fence)
+			for _, l := range lines {
+				fmt.Fprintf(buf, "  %s\n", l)
+			}
+			fmt.Fprintf(buf, "  %s\n\n", fence)
+		}
+	}
+	section("Incompatible changes", "api-incompatible", incompatible)
+	section("Compatible changes", "api-compatible", compatible)
+
+	_, err = w.Write(shiftHeadings(anchors(normalize(buf.Bytes()), a), config.HeadingOffset))
+	return len(incompatible) > 0, err
+}
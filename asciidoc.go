@@ -0,0 +1,117 @@
+package godoc2md
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// asciidocRenderer renders an AsciiDoc document.
+type asciidocRenderer struct{}
+
+func (asciidocRenderer) Begin(w io.Writer, p Page) {
+	if p.Command {
+		fmt.Fprintf(w, "= %s\n", adocText(p.Name))
+	} else {
+		fmt.Fprintf(w, "= package %s\n", adocText(p.Name))
+	}
+	if p.Synopsis != "" {
+		fmt.Fprintf(w, ":description: %s\n", adocText(p.Synopsis))
+	}
+	io.WriteString(w, "\n")
+	if !p.Command {
+		fmt.Fprintf(w, "`+import %q+`\n\n", p.ImportPath)
+	}
+}
+
+func (asciidocRenderer) End(w io.Writer) {}
+
+func (asciidocRenderer) Heading(w io.Writer, level int, spans []Span, id string) {
+	fmt.Fprintf(w, "[[%s]]\n%s %s\n\n", id, strings.Repeat("=", level), adocSpans(spans))
+}
+
+func (asciidocRenderer) Paragraph(w io.Writer, spans []Span) {
+	fmt.Fprintf(w, "%s\n\n", adocLine(adocSpans(spans)))
+}
+
+func (asciidocRenderer) Code(w io.Writer, lang, code string) {
+	if lang != "" {
+		fmt.Fprintf(w, "[source,%s]\n", lang)
+	}
+	fence := "----"
+	for strings.Contains(code, fence) {
+		fence += "-"
+	}
+	fmt.Fprintf(w, "%s\n%s\n%s\n\n", fence, code, fence)
+}
+
+func (asciidocRenderer) List(w io.Writer, items []Item) {
+	for _, it := range items {
+		fmt.Fprintf(w, "%s %s\n", strings.Repeat("*", it.Level), adocSpans(it.Spans))
+	}
+	io.WriteString(w, "\n")
+}
+
+func (asciidocRenderer) Table(w io.Writer, header []string, rows [][]string) {
+	fmt.Fprintf(w, "[cols=\"%s\",options=\"header\"]\n|===\n", strings.TrimSuffix(strings.Repeat("1,", len(header)), ","))
+	for _, h := range header {
+		fmt.Fprintf(w, "|%s ", adocCell(h))
+	}
+	io.WriteString(w, "\n")
+	for _, row := range rows {
+		for _, c := range row {
+			fmt.Fprintf(w, "|%s ", adocCell(c))
+		}
+		io.WriteString(w, "\n")
+	}
+	io.WriteString(w, "|===\n\n")
+}
+
+// adocSpans returns the spans as AsciiDoc text: links are url[text], links into the document <<id,text>>.
+func adocSpans(spans []Span) string {
+	b := &strings.Builder{}
+	for _, s := range spans {
+		switch {
+		case s.URL == "":
+			b.WriteString(adocText(s.Text))
+		case strings.HasPrefix(s.URL, "#"):
+			fmt.Fprintf(b, "<<%s,%s>>", s.URL[1:], adocText(s.Text))
+		case s.URL == s.Text:
+			b.WriteString(s.URL)
+		default:
+			fmt.Fprintf(b, "link:%s[%s]", adocURL(s.URL), strings.Replace(s.Text, "]", `\]`, -1))
+		}
+	}
+	return b.String()
+}
+
+// adocText returns s as literal AsciiDoc text: text with markup characters is put in a +...+
+// passthrough, or in pass:c[...] if it holds a "+".
+func adocText(s string) string {
+	s = strings.Replace(s, "\n", " ", -1)
+	if s == "" || !strings.ContainsAny(s, "*_`#^~+[]{}<>\\") {
+		return s
+	}
+	if strings.Contains(s, "+") {
+		return "pass:c[" + strings.Replace(s, "]", `\]`, -1) + "]"
+	}
+	return "+" + s + "+"
+}
+
+// adocURL escapes the characters in url that end a link macro's target.
+func adocURL(url string) string {
+	return strings.NewReplacer(" ", "%20", "[", "%5B").Replace(url)
+}
+
+// adocLine keeps a paragraph from being read as a list, a block title or another block.
+func adocLine(s string) string {
+	for _, p := range []string{"* ", "- ", ". ", "=", "[", "|", "//", "----", "...."} {
+		if strings.HasPrefix(s, p) {
+			return "{empty}" + s
+		}
+	}
+	return s
+}
+
+// adocCell returns s as the text of a table cell.
+func adocCell(s string) string { return strings.Replace(adocText(s), "|", `\|`, -1) }
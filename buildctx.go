@@ -0,0 +1,199 @@
+package godoc2md
+
+import (
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// BuildContext selects the files of a package that are documented, as the go command does when building
+// for a platform.
+type BuildContext struct {
+	GOOS   string   // Defaults to the GOOS of the build.Default context.
+	GOARCH string   // Defaults to the GOARCH of the build.Default context.
+	Tags   []string // Extra build tags, e.g. "integration".
+	Cgo    bool     // Include cgo files, off by default as when cross compiling.
+}
+
+// ParseBuildContext parses a build context written as goos[/goarch][+tag...], e.g. "linux",
+// "windows/amd64" or "linux+cgo+integration". The tag "cgo" enables cgo. Empty parts are left at
+// their defaults: "/arm64" is arm64 on the default GOOS.
+func ParseBuildContext(s string) (BuildContext, error) {
+	parts := strings.Split(s, "+")
+	b := BuildContext{}
+	platform := strings.SplitN(parts[0], "/", 2)
+	b.GOOS = platform[0]
+	if len(platform) > 1 {
+		b.GOARCH = platform[1]
+	}
+	for _, tag := range parts[1:] {
+		switch tag {
+		case "":
+			return b, fmt.Errorf("empty build tag in %q", s)
+		case "cgo":
+			b.Cgo = true
+		default:
+			b.Tags = append(b.Tags, tag)
+		}
+	}
+	return b, nil
+}
+
+// String returns the build context as parsed by ParseBuildContext.
+func (b BuildContext) String() string {
+	s := b.GOOS
+	if b.GOARCH != "" {
+		s += "/" + b.GOARCH
+	}
+	if b.Cgo {
+		s += "+cgo"
+	}
+	for _, tag := range b.Tags {
+		s += "+" + tag
+	}
+	return s
+}
+
+// context returns build.Default configured for b. It must be called with buildMu held.
+func (b BuildContext) context() build.Context {
+	ctxt := build.Default
+	if b.GOOS != "" {
+		ctxt.GOOS = b.GOOS
+	}
+	if b.GOARCH != "" {
+		ctxt.GOARCH = b.GOARCH
+	}
+	ctxt.BuildTags = b.Tags
+	ctxt.CgoEnabled = b.Cgo
+	return ctxt
+}
+
+// buildMu guards build.Default, which godoc picks the files of a package with. withBuildContext changes
+// it with the write lock held, everything else in this package that reads it holds the read lock, see
+// defaultContext. Code outside this package that reads build.Default isn't protected.
+var buildMu sync.RWMutex
+
+// defaultContext returns a copy of build.Default.
+func defaultContext() build.Context {
+	buildMu.RLock()
+	defer buildMu.RUnlock()
+	return build.Default
+}
+
+// osFS returns the file system of the directory root on disk, vfs.OS reads build.Default.
+func osFS(root string) vfs.FileSystem {
+	buildMu.RLock()
+	defer buildMu.RUnlock()
+	return vfs.OS(root)
+}
+
+// withBuildContext binds src, the package directory path, into fs and calls f with build.Default set
+// up for config.BuildContext or config.Matrix. For a matrix, all files that are built in at least one
+// of its contexts are used, src must then be the directory path on disk. The Go files of other packages
+// than the one documented are hidden, see otherFiles.
+//
+// As godoc only uses build.Default, this changes process-global state: concurrent calls are serialized
+// with buildMu, and build.Default is restored before returning.
+func withBuildContext(fs vfs.NameSpace, path string, src vfs.FileSystem, config *Config, f func()) error {
+	bind := func(src vfs.FileSystem) error {
+		src, err := selectPackage(src, path, config)
+		if err != nil {
+			return err
+		}
+		fs.Bind(path, src, "/", vfs.BindReplace)
+		f()
+		return nil
+	}
+	if config.BuildContext == nil && len(config.Matrix) == 0 {
+		buildMu.RLock()
+		defer buildMu.RUnlock()
+		return bind(src)
+	}
+
+	buildMu.Lock()
+	defer buildMu.Unlock()
+	saved := build.Default
+	defer func() { build.Default = saved }()
+
+	if len(config.Matrix) == 0 {
+		build.Default = config.BuildContext.context()
+		return bind(src)
+	}
+	ctxts := make([]build.Context, len(config.Matrix))
+	for i, b := range config.Matrix {
+		ctxts[i] = b.context()
+	}
+	build.Default.UseAllFiles = true // matrixFS does the selection
+	return bind(matrixFS{FileSystem: src, root: path, ctxts: ctxts})
+}
+
+// matrixFS is a file system that only shows the Go files that match at least one of the build contexts.
+type matrixFS struct {
+	vfs.FileSystem
+	root  string
+	ctxts []build.Context
+}
+
+func (m matrixFS) ReadDir(p string) ([]os.FileInfo, error) {
+	fis, err := m.FileSystem.ReadDir(p)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(m.root, filepath.FromSlash(p))
+	var out []os.FileInfo
+	for _, fi := range fis {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), ".go") {
+			out = append(out, fi)
+			continue
+		}
+		for i := range m.ctxts {
+			if ok, err := m.ctxts[i].MatchFile(dir, fi.Name()); err == nil && ok {
+				out = append(out, fi)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// availability returns for the functions, types and methods of the package in path that are not built
+// in every context of config.Matrix, the contexts they are built in. The symbols are keyed by their
+// godoc id: F, T or T.M.
+func availability(path string, config *Config) map[string][]string {
+	in := map[string][]string{}
+	for _, b := range config.Matrix {
+		b := b
+		c := *config
+		c.Matrix, c.BuildContext = nil, &b
+		syms, err := symbols(path, &c)
+		if err != nil {
+			continue // the package may not exist in this context
+		}
+		for _, s := range syms {
+			in[s] = append(in[s], b.String())
+		}
+	}
+	for s, ctxts := range in {
+		if len(ctxts) == len(config.Matrix) {
+			delete(in, s)
+		}
+	}
+	return in
+}
+
+// platformsRx matches the contexts a heading's symbol is built in, as added by the template.
+var platformsRx = regexp.MustCompile(` \*[^*]+ only\*$`)
+
+// platformsMd returns the annotation for a symbol that is only built in the contexts ctxts.
+func platformsMd(ctxts []string) string {
+	if len(ctxts) == 0 {
+		return ""
+	}
+	return " *" + escapeMd(strings.Join(ctxts, ", "), ctxPara) + " only*"
+}
@@ -0,0 +1,79 @@
+package godoc2md
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuildMatrix(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"p.go":         "// Package p is portable.\npackage p\n\n// F is everywhere.\nfunc F() {}\n",
+		"p_linux.go":   "package p\n\n// L is only on Linux.\nfunc L() {}\n\n// U is on Unix.\nfunc U() {}\n",
+		"p_darwin.go":  "package p\n\n// U is on Unix.\nfunc U() {}\n",
+		"p_windows.go": "package p\n\n// W is only on Windows.\nfunc W() {}\n",
+		"p_cgo.go":     "//go:build cgo\n\npackage p\n\n// C needs cgo.\nfunc C() {}\n",
+	}
+	writeFiles(t, dir, files)
+
+	config := &Config{Import: "example.com/p", BuildContext: &BuildContext{GOOS: "windows"}}
+	syms, err := symbols(dir, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"F", "W"}, syms); diff != "" {
+		t.Errorf("unexpected symbols for windows (-want +got):\n%s", diff)
+	}
+
+	var matrix []BuildContext
+	for _, s := range []string{"linux", "darwin", "windows", "linux+cgo"} {
+		b, err := ParseBuildContext(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		matrix = append(matrix, b)
+	}
+	config = &Config{Import: "example.com/p", SrcLinkHashFormat: "#L%d", Matrix: matrix}
+	buf := &bytes.Buffer{}
+	if err := Transform(buf, dir, config); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"#L5) {#F}\n",
+		"#L6) *linux+cgo only* {#C}\n",
+		"#L4) *linux, linux+cgo only* {#L}\n",
+		"*linux, darwin, linux+cgo only* {#U}\n",
+		"#L4) *windows only* {#W}\n",
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q in output, got:\n%s", s, buf.String())
+		}
+	}
+
+	// build.Default is changed for the windows context, concurrent calls must not see that
+	host, err := symbols(dir, &Config{Import: "example.com/p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if syms, err := symbols(dir, &Config{Import: "example.com/p", BuildContext: &BuildContext{GOOS: "windows"}}); err != nil || !cmp.Equal(syms, []string{"F", "W"}) {
+				t.Errorf("unexpected symbols for windows: %v, %v", syms, err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if syms, err := symbols(dir, &Config{Import: "example.com/p"}); err != nil || !cmp.Equal(syms, host) {
+				t.Errorf("expected symbols %v of the host, got %v, %v", host, syms, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,152 @@
+package godoc2md
+
+import (
+	"fmt"
+	"go/doc"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/godoc"
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// typeCheck parses and type checks the package in the directory dir of ns. Type errors, for instance because an import
+// can't be found, are ignored: the result is still useful for the package's own types.
+func typeCheck(ns vfs.NameSpace, dir string) (*types.Package, error) {
+	fset := token.NewFileSet()
+	bpkg, files, err := parseDir(ns, dir, fset, 0)
+	if err != nil {
+		return nil, err
+	}
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error:    func(error) {},
+	}
+	buildMu.RLock() // the source importer uses build.Default
+	defer buildMu.RUnlock()
+	pkg, _ := conf.Check(bpkg.ImportPath, fset, files, nil)
+	return pkg, nil
+}
+
+// classDiagram returns a Mermaid class diagram of the exported types in the package in info: structs
+// with their exported fields, embedding, interfaces and the types in the package implementing them, and
+// the functions returning each type. Each class links to the type's heading. It returns the empty string
+// if the package has no types.
+func classDiagram(ns vfs.NameSpace, info *godoc.PageInfo) (string, error) {
+	if info == nil || info.PDoc == nil || len(info.PDoc.Types) == 0 {
+		return "", nil
+	}
+	pkg, err := typeCheck(ns, info.Dirname)
+	if err != nil {
+		return "", err
+	}
+
+	b := &strings.Builder{}
+	b.WriteString("```mermaid\nclassDiagram\n")
+	var relations []string
+	for _, t := range info.PDoc.Types {
+		obj, _ := pkg.Scope().Lookup(t.Name).(*types.TypeName)
+		if obj == nil {
+			fmt.Fprintf(b, "    class %s\n", t.Name)
+			continue
+		}
+		members, rels := classMembers(pkg, obj, t)
+		relations = append(relations, rels...)
+		if len(members) == 0 {
+			fmt.Fprintf(b, "    class %s\n", t.Name)
+		} else {
+			fmt.Fprintf(b, "    class %s {\n", t.Name)
+			for _, m := range members {
+				fmt.Fprintf(b, "        %s\n", m)
+			}
+			b.WriteString("    }\n")
+		}
+		if types.IsInterface(obj.Type()) {
+			fmt.Fprintf(b, "    <<interface>> %s\n", t.Name)
+		}
+	}
+	for _, r := range relations {
+		fmt.Fprintf(b, "    %s\n", r)
+	}
+	for _, t := range info.PDoc.Types {
+		fmt.Fprintf(b, "    click %s href \"#%s\"\n", t.Name, t.Name)
+	}
+	b.WriteString("```\n")
+	return b.String(), nil
+}
+
+// classMembers returns the members of the class for type t, and its relations with the other types
+// in the package.
+func classMembers(pkg *types.Package, obj *types.TypeName, t *doc.Type) (members, relations []string) {
+	qualifier := func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		return p.Name()
+	}
+
+	switch u := obj.Type().Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			f := u.Field(i)
+			if !f.Exported() {
+				continue
+			}
+			if f.Embedded() {
+				if n := namedType(f.Type()); n != nil && n.Obj().Pkg() == pkg {
+					relations = append(relations, fmt.Sprintf("%s *-- %s", obj.Name(), n.Obj().Name()))
+					continue
+				}
+			}
+			members = append(members, "+"+f.Name()+" "+mermaidType(types.TypeString(f.Type(), qualifier)))
+		}
+	case *types.Interface:
+		for i := 0; i < u.NumExplicitMethods(); i++ {
+			if m := u.ExplicitMethod(i); m.Exported() {
+				members = append(members, "+"+m.Name()+"()")
+			}
+		}
+		for i := 0; i < u.NumEmbeddeds(); i++ {
+			if n := namedType(u.EmbeddedType(i)); n != nil && n.Obj().Pkg() == pkg {
+				relations = append(relations, fmt.Sprintf("%s <|-- %s", n.Obj().Name(), obj.Name()))
+			}
+		}
+		if u.NumMethods() == 0 {
+			break
+		}
+		// implementers in this package
+		for _, name := range pkg.Scope().Names() {
+			tn, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+			if !ok || !tn.Exported() || tn == obj || types.IsInterface(tn.Type()) {
+				continue
+			}
+			if types.Implements(tn.Type(), u) || types.Implements(types.NewPointer(tn.Type()), u) {
+				relations = append(relations, fmt.Sprintf("%s <|.. %s", obj.Name(), tn.Name()))
+			}
+		}
+	}
+	for _, f := range t.Funcs {
+		members = append(members, "+"+f.Name+"()$")
+	}
+	return members, relations
+}
+
+// namedType returns the named type of t, dereferencing pointers, or nil.
+func namedType(t types.Type) *types.Named {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	n, _ := t.(*types.Named)
+	return n
+}
+
+// mermaidType shortens the type string s so it can be used as the type of a member: parentheses would
+// turn the member into a method and braces would end the class.
+func mermaidType(s string) string {
+	if i := strings.IndexAny(s, "({"); i >= 0 {
+		return s[:i] + "..."
+	}
+	return s
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/miekg/godoc2md"
+)
+
+// diffMain implements "godoc2md diff [flags] old new [dir]", old and new are package directories or
+// git refs of the repository dir (default ".") is in.
+func diffMain(args []string) { os.Exit(diffRun(args)) }
+
+// diffRun runs diffMain and returns the exit code, after removing the checkouts of the git refs.
+func diffRun(args []string) (code int) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	ci := fs.Bool("ci", false, "exit with status 1 if there are incompatible changes")
+	out := fs.String("o", "", "write the report to this file, instead of standard output")
+	imp := fs.String("import", "", "import path for the package, used in the report's title")
+	flavor := fs.String("flavor", "mmark", "markdown flavor to generate: mmark, github, gitlab or bitbucket")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: godoc2md diff [options] old new [dir]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 2 || fs.NArg() > 3 {
+		fs.Usage()
+		return 2
+	}
+	dir := "."
+	if fs.NArg() == 3 {
+		dir = fs.Arg(2)
+	}
+
+	paths := make([]string, 2)
+	for i, arg := range fs.Args()[:2] {
+		if fi, err := os.Stat(arg); err == nil && fi.IsDir() {
+			paths[i], _ = filepath.Abs(arg)
+			continue
+		}
+		p, cleanup, err := godoc2md.Checkout(arg, dir)
+		if err != nil {
+			log.Printf("%s is not a directory nor a git ref: %s", arg, err)
+			return 1
+		}
+		defer cleanup()
+		paths[i] = p
+	}
+
+	config := &godoc2md.Config{Import: *imp, Flavor: *flavor}
+	changes, err := godoc2md.APIDiff(paths[0], paths[1], config)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	title := "API changes"
+	if *imp != "" {
+		title += " in " + *imp
+	}
+	title += " from " + fs.Arg(0) + " to " + fs.Arg(1)
+
+	buf := &bytes.Buffer{}
+	incompatible, err := godoc2md.APIReport(buf, title, changes, config)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if *out != "" {
+		err = os.WriteFile(*out, buf.Bytes(), 0644)
+	} else {
+		_, err = os.Stdout.Write(buf.Bytes())
+	}
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if *ci && incompatible {
+		return 1
+	}
+	return 0
+}
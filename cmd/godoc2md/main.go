@@ -8,15 +8,114 @@
 // Usage
 //
 //    godoc2md $PACKAGE > $GOPATH/src/$PACKAGE/README.md
+//
+// With -o the output is written to a file in each package directory. With -check nothing is
+// written, but the existing files are compared with the generated documentation; for each stale file
+// a unified diff is printed and godoc2md exits with status 1. This is useful in CI.
+//
+//    godoc2md -check -o README.md $PACKAGE
+//
+// With -inject only the part of an existing (hand written) file between the markers
+// <!-- godoc2md:begin --> and <!-- godoc2md:end --> is replaced. Sections can be selected with
+// <!-- godoc2md:begin:index -->, <!-- godoc2md:begin:type=Client -->, etc. Headings are shifted to
+// fit under the heading preceding the marker. -check also understands these markers.
+//
+//    godoc2md -inject README.md $PACKAGE
+//
+// With -index an index of all packages is written, -graph adds the import graph as a Mermaid
+// diagram. -dot writes the import graph in the DOT language.
+//
+//    godoc2md -index index.md -graph -dot imports.dot $PACKAGE
+//
+// With -lint no documentation is generated, but the documentation is checked: exported symbols without
+// a doc comment, doc links that don't resolve, etc. The documentation coverage of each package is
+// reported too, with -lint-threshold godoc2md exits with status 1 if a package's coverage is below it.
+//
+//    godoc2md -lint -lint-format markdown -lint-threshold 80 $PACKAGE
+//
+// The files of a package that are documented are the ones built for the host, -goos, -goarch, -tags
+// and -cgo select others. With -matrix the files built in any of a list of build contexts are
+// documented, and functions, types and methods that aren't in all of them are annotated with the
+// contexts they are in.
+//
+//    godoc2md -matrix linux,darwin,windows,linux+cgo $PACKAGE
+//
+// With -verify-examples the examples are built, against the package's code on disk, and run like go
+// test does. Failing examples, and their output, are reported after generating the documentation and
+// godoc2md exits with status 1.
+//
+//    godoc2md -verify-examples -o README.md $PACKAGE
+//
+// With -check-snippets the Go code blocks in doc comments and in README.md are type checked, each on
+// its own with the package and the standard library imported. Code blocks containing godoc2md:nocheck,
+// or in markdown preceded by <!-- godoc2md:nocheck -->, are skipped.
+//
+//    godoc2md -check-snippets $PACKAGE
+//
+// In a directory with several packages, e.g. a stray package main next to the library, the package
+// named after the directory, or else the only one that isn't a command, is documented. Files excluded by
+// build constraints, like //go:build ignore, don't count. If the choice is ambiguous all packages are
+// reported, and -package selects one.
+//
+// A package can also be documented from the module cache, without cloning anything: an argument
+// written as import/path@version, or an import path that isn't a directory, is looked up in $GOMODCACHE
+// (or -modcache), in the extracted modules and in the downloaded module zips. Without a version the
+// latest one in the cache is used. The import path and the source links, to the version's tag, are
+// set from the cache. Only -o can be used, it writes the file in the current directory.
+//
+//    godoc2md github.com/miekg/dns@v1.1.50
+//
+// Nested go.mod files, e.g. in tools/ or v2/, and the modules of a go.work are detected: the packages in
+// a module get their import path from its go.mod, and source links point into the repository, whose
+// import path is -import or the root module's path without its major version suffix. The index groups
+// the packages by module.
+//
+// With -format the documentation is written as standalone HTML, AsciiDoc, reStructuredText or a man
+// page (section 3 for packages, section 1 for commands) instead of markdown. The markdown options,
+// like -flavor, don't apply to these formats.
+//
+//    godoc2md -format man -o godoc2md.1 ./cmd/godoc2md
+//
+// With -quickref only a cheat sheet is written, like go doc -short: the index, with the one line
+// synopsis of each symbol and links to the source, without the comments and declarations. With
+// -quickref-budget the synopses are truncated so the output fits in that many bytes, if it can.
+//
+//    godoc2md -quickref -quickref-budget 8192 $PACKAGE
+//
+// With -split the documentation of a large package is split over several pages in the package
+// directory: the file named by -o (default README.md) holds the overview, the index, the constants,
+// variables and notes, functions.md the functions, and type-T.md the type T with its constants,
+// variables, constructors and methods. Links between the pages point to the right file. Pages of
+// types that no longer exist are removed. With -check all pages are compared, and orphaned pages are
+// reported as stale.
+//
+//    godoc2md -split -o README.md $PACKAGE
+//
+// With -site a site for a static site generator (hugo, mkdocs or docusaurus) is written to the
+// directory -site-dir: a page for each package with front matter (-front-matter yaml or toml) holding
+// its title, weight, import path, synopsis and module version (-site-version, defaults to the latest
+// release tag), and the generator's navigation, mirroring the package tree: _index.md files for Hugo,
+// nav.yml for MkDocs and sidebars.json for Docusaurus.
+//
+//    godoc2md -site hugo -site-dir content/api -front-matter toml $PACKAGE
+//
+// The diff command reports the changes to the exported API of a package between two directories, or
+// two git refs of the repository. Each change is classified as compatible or incompatible, with -ci
+// godoc2md exits with status 1 if there are incompatible changes.
+//
+//    godoc2md diff -ci v1.2.0 HEAD $PACKAGE
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/miekg/godoc2md"
 )
@@ -38,6 +137,59 @@ var (
 	flgImport  = flag.String("import", "", "import path for the package")
 	flgReplace = flag.String("replace", "", "replace package source with import path")
 	flgRef     = flag.String("gitref", "master", "git ref to use for generating the files' link")
+
+	flgFormat        = flag.String("format", "markdown", "output format: markdown, html, asciidoc, rst or man")
+	flgFlavor        = flag.String("flavor", "mmark", "markdown flavor to generate: mmark, github, gitlab or bitbucket")
+	flgAnchors       = flag.String("anchors", "", "anchor style: godoc, mmark, github, gitlab or bitbucket, defaults to the flavor's")
+	flgHeadingOffset = flag.Int("heading-offset", 0, "shift all headings this many levels down")
+	flgTOCDepth      = flag.Int("toc-depth", 0, "depth of the index and tables of contents, 0 is unlimited, -1 disables them")
+	flgTOCDetails    = flag.Bool("toc-details", false, "render tables of contents in collapsible <details> blocks (github and gitlab only)")
+	flgQuickRef      = flag.Bool("quickref", false, "only write a quick reference: the exported signatures with their synopsis")
+	flgQuickRefSize  = flag.Int("quickref-budget", 0, "truncate the synopses of -quickref to fit in this many bytes, 0 is unlimited")
+	flgPackage       = flag.String("package", "", "package to document in directories with several packages, e.g. main")
+	flgSchemes       = flag.String("schemes", "", "comma separated list of extra URL schemes to linkify, e.g. ssh,git")
+	flgNoLinkify     = flag.Bool("nolinkify", false, "don't turn URLs in comments into links")
+	flgLang          = flag.String("lang", "", "language of code blocks in comments that aren't recognized, defaults to text")
+	flgNoGuessLang   = flag.Bool("noguess", false, "don't guess the language of code blocks in comments, always use -lang")
+	flgClassDiagram  = flag.Bool("classes", false, "include a Mermaid class diagram of the package's types in the overview")
+	flgSince         = flag.Bool("since", false, "show the release (semver git tag) in which functions, types and methods were added")
+	flgSinceCache    = flag.String("since-cache", "", "directory of the cache used by -since, defaults to the user's cache directory")
+
+	flgGOOS   = flag.String("goos", "", "document the files built for this GOOS, defaults to the host's")
+	flgGOARCH = flag.String("goarch", "", "document the files built for this GOARCH, defaults to the host's")
+	flgTags   = flag.String("tags", "", "comma separated list of build tags to document the files of")
+	flgCgo    = flag.Bool("cgo", false, "document the files that need cgo, with -goos, -goarch or -tags")
+	flgMatrix = flag.String("matrix", "", "comma separated list of build contexts, goos[/goarch][+tag...], to document the files of, symbols not in all of them are annotated")
+
+	flgModCache = flag.String("modcache", "", "module cache to find import/path@version packages in, defaults to $GOMODCACHE")
+
+	flgSplit = flag.Bool("split", false, "split the documentation into an overview (-o, default README.md), functions.md and a type-T.md page per type")
+	flgOut   = flag.String("o", "", "write the output to this file in each package directory, instead of standard output")
+	flgCheck = flag.Bool("check", false, "check that the files named by -o (default README.md) are up to date, print a diff if not")
+
+	flgInject = flag.String("inject", "", "inject the output between the godoc2md markers in this file in each package directory")
+
+	flgLint          = flag.Bool("lint", false, "report documentation issues and coverage instead of generating documentation")
+	flgLintFormat    = flag.String("lint-format", "text", "output format of -lint: text, json or markdown")
+	flgLintThreshold = flag.Float64("lint-threshold", 0, "with -lint, exit with status 1 if a package's documentation coverage (in percent) is below this")
+
+	flgCheckSnippets = flag.Bool("check-snippets", false, "type check the Go code blocks in doc comments and README.md files, exit with status 1 if any don't compile")
+
+	flgVerifyExamples = flag.Bool("verify-examples", false, "build and run the examples and check their output, exit with status 1 if any fail")
+	flgExampleTimeout = flag.Duration("example-timeout", 10*time.Second, "maximum time an example may run for, with -verify-examples")
+
+	flgSite        = flag.String("site", "", "write a site for this static site generator: hugo, mkdocs or docusaurus")
+	flgSiteDir     = flag.String("site-dir", "site", "directory the -site pages and navigation are written to")
+	flgFrontMatter = flag.String("front-matter", "yaml", "front matter format of the -site pages: yaml or toml")
+	flgSiteVersion = flag.String("site-version", "", "module version in the -site front matter, defaults to the latest release tag")
+
+	flgIndex          = flag.String("index", "", "write an index of all packages to this file in the root directory")
+	flgGraph          = flag.Bool("graph", false, "include the import graph in the index, as a Mermaid diagram")
+	flgDOT            = flag.String("dot", "", "write the import graph in the DOT language to this file in the root directory")
+	flgGraphExternal  = flag.Bool("graph-external", false, "include imports from outside the module in the import graph")
+	flgGraphStd       = flag.Bool("graph-std", false, "collapse the standard library into a single node in the import graph")
+	flgGraphNoTests   = flag.Bool("graph-notests", false, "leave out test imports from the import graph")
+	flgGraphHighlight = flag.Bool("graph-highlight", false, "highlight internal packages and import cycles in the import graph")
 )
 
 func usage() {
@@ -47,6 +199,11 @@ func usage() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		diffMain(os.Args[2:])
+		return
+	}
+
 	flag.Usage = usage
 	flag.Parse()
 	if flag.NArg() != 1 {
@@ -63,9 +220,110 @@ func main() {
 		Replace:           *flgReplace,
 		Import:            *flgImport,
 		GitRef:            *flgRef,
+		Format:            *flgFormat,
+		Flavor:            *flgFlavor,
+		Anchors:           *flgAnchors,
+		HeadingOffset:     *flgHeadingOffset,
+		TOCDepth:          *flgTOCDepth,
+		TOCDetails:        *flgTOCDetails,
+		QuickRef:          *flgQuickRef,
+		QuickRefBudget:    *flgQuickRefSize,
+		NoLinkify:         *flgNoLinkify,
+		CodeLang:          *flgLang,
+		NoGuessLang:       *flgNoGuessLang,
+		ClassDiagram:      *flgClassDiagram,
+		Since:             *flgSince,
+		SinceCache:        *flgSinceCache,
+		VerifyExamples:    *flgVerifyExamples,
+		ExampleTimeout:    *flgExampleTimeout,
+		PackageName:       *flgPackage,
+	}
+	if *flgSchemes != "" {
+		config.URLSchemes = strings.Split(*flgSchemes, ",")
+	}
+	if *flgGOOS != "" || *flgGOARCH != "" || *flgTags != "" || *flgCgo {
+		config.BuildContext = &godoc2md.BuildContext{GOOS: *flgGOOS, GOARCH: *flgGOARCH, Cgo: *flgCgo}
+		if *flgTags != "" {
+			config.BuildContext.Tags = strings.Split(*flgTags, ",")
+		}
+	}
+	if *flgMatrix != "" {
+		for _, s := range strings.Split(*flgMatrix, ",") {
+			b, err := godoc2md.ParseBuildContext(s)
+			if err != nil {
+				log.Fatal(err)
+			}
+			config.Matrix = append(config.Matrix, b)
+		}
+	}
+
+	if cached, err := cachedPackage(pkgName); err != nil {
+		log.Fatal(err)
+	} else if cached != nil {
+		if err := documentCached(cached, config); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	graph := godoc2md.GraphOptions{
+		External:    *flgGraphExternal,
+		CollapseStd: *flgGraphStd,
+		NoTests:     *flgGraphNoTests,
+		Highlight:   *flgGraphHighlight,
+	}
+	if *flgSite != "" {
+		opts := godoc2md.SiteOptions{Generator: *flgSite, FrontMatter: *flgFrontMatter, Version: *flgSiteVersion}
+		if err := godoc2md.Site(pkgName, *flgSiteDir, opts, config); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *flgIndex != "" || *flgDOT != "" {
+		if *flgGraph {
+			config.Graph = &graph
+		}
+		if err := index(pkgName, config, graph, *flgIndex, *flgDOT); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *flgInject != "" {
+		*flgOut = *flgInject
+	}
+	if (*flgCheck || *flgSplit) && *flgOut == "" {
+		*flgOut = "README.md"
 	}
 
-	err := filepath.Walk(pkgName,
+	stale := false
+	var reports []*godoc2md.LintReport
+	// transformed reports the error returned by Transform or Check. Failing examples are reported, but
+	// don't stop the documentation from being used. It returns false if there is no documentation.
+	transformed := func(err error) bool {
+		if e, ok := err.(*godoc2md.ExamplesError); ok {
+			for _, r := range e.Results {
+				log.Printf("%s: %s: %s", r.Pos, r.Name, r.Message)
+				if r.Status == "fail" {
+					log.Printf("got:\n%s\nwant:\n%s", r.Got, r.Want)
+				}
+			}
+			stale = true
+			return true
+		}
+		if err != nil {
+			log.Println(err)
+			return false
+		}
+		return true
+	}
+	transform := func(w io.Writer, p string) bool { return transformed(godoc2md.Transform(w, p, config)) }
+	mods, err := godoc2md.FindModules(pkgName, config.Import)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = filepath.Walk(pkgName,
 		func(p string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
@@ -78,18 +336,213 @@ func main() {
 				return nil
 			}
 			imp := config.Import
-			defer func() { config.Import = imp; config.SubPackage = "" }()
-			if rel != "" && rel != "." {
-				config.Import += "/" + rel
-				config.SubPackage = rel
-			}
+			defer func() { config.Import = imp; config.SubPackage = ""; config.Repo = "" }()
+			mods.Configure(rel, config)
 
-			if err := godoc2md.Transform(os.Stdout, p, config); err != nil {
-				log.Println(err)
+			switch {
+			case *flgCheckSnippets:
+				if !hasGoFiles(p) {
+					return nil
+				}
+				issues, err := godoc2md.CheckSnippets(p, config)
+				if err != nil {
+					log.Println(err)
+					return nil
+				}
+				for _, i := range issues {
+					fmt.Printf("%s: %s\n", i.Pos, i.Message)
+					stale = true
+				}
+			case *flgLint:
+				if !hasGoFiles(p) {
+					return nil
+				}
+				r, err := godoc2md.Lint(p, config)
+				if err != nil {
+					log.Println(err)
+					return nil
+				}
+				reports = append(reports, r)
+			case *flgCheck:
+				var diff string
+				var err error
+				if *flgSplit {
+					diff, err = godoc2md.CheckSplit(p, *flgOut, config)
+				} else {
+					diff, err = godoc2md.Check(filepath.Join(p, *flgOut), p, config)
+				}
+				if !transformed(err) {
+					return nil
+				}
+				if diff != "" {
+					stale = true
+					fmt.Print(diff)
+				}
+			case *flgInject != "":
+				file := filepath.Join(p, *flgInject)
+				readme, err := os.ReadFile(file)
+				if err != nil || !godoc2md.HasMarkers(readme) {
+					return nil
+				}
+				buf := &bytes.Buffer{}
+				if !transform(buf, p) {
+					return nil
+				}
+				injected, err := godoc2md.Inject(readme, buf.Bytes())
+				if err != nil {
+					log.Printf("%s: %s", file, err)
+					return nil
+				}
+				if bytes.Equal(injected, readme) {
+					return nil
+				}
+				if err := os.WriteFile(file, injected, 0644); err != nil {
+					log.Println(err)
+				}
+			case *flgSplit:
+				pages, err := godoc2md.TransformSplit(p, *flgOut, config)
+				if !transformed(err) {
+					return nil
+				}
+				for name, page := range pages {
+					if err := os.WriteFile(filepath.Join(p, name), page, 0644); err != nil {
+						log.Println(err)
+					}
+				}
+				orphans, err := godoc2md.OrphanedPages(p, pages)
+				if err != nil {
+					log.Println(err)
+				}
+				for _, name := range orphans {
+					if err := os.Remove(filepath.Join(p, name)); err != nil {
+						log.Println(err)
+					}
+				}
+			case *flgOut != "":
+				buf := &bytes.Buffer{}
+				if !transform(buf, p) {
+					return nil
+				}
+				if buf.Len() == 0 {
+					return nil
+				}
+				if err := os.WriteFile(filepath.Join(p, *flgOut), buf.Bytes(), 0644); err != nil {
+					log.Println(err)
+				}
+			default:
+				transform(os.Stdout, p)
 			}
 			return nil
 		})
 	if err != nil {
 		log.Fatal(err)
 	}
+	if *flgLint {
+		stale = lint(reports, config)
+	}
+	if stale {
+		os.Exit(1)
+	}
+}
+
+// lint writes the lint reports to standard output, in the format given by -lint-format. It returns true
+// if a package's coverage is below -lint-threshold.
+func lint(reports []*godoc2md.LintReport, config *godoc2md.Config) bool {
+	var err error
+	switch *flgLintFormat {
+	case "text":
+		err = godoc2md.LintText(os.Stdout, reports)
+	case "json":
+		err = godoc2md.LintJSON(os.Stdout, reports)
+	case "markdown":
+		err = godoc2md.LintMarkdown(os.Stdout, reports, config)
+	default:
+		log.Fatalf("unknown lint format: %q", *flgLintFormat)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	below := false
+	for _, r := range reports {
+		if r.Coverage < *flgLintThreshold {
+			log.Printf("%s: coverage %.1f%% is below %.1f%%", r.ImportPath, r.Coverage, *flgLintThreshold)
+			below = true
+		}
+	}
+	return below
+}
+
+// cachedPackage returns the package in the module cache that arg, import/path@version or an import path
+// that isn't a directory, refers to. It returns nil if arg is a directory.
+func cachedPackage(arg string) (*godoc2md.CachedPackage, error) {
+	if !strings.Contains(arg, "@") {
+		if _, err := os.Stat(arg); err == nil || filepath.IsAbs(arg) || strings.HasPrefix(arg, ".") {
+			return nil, nil
+		}
+	}
+	return godoc2md.LookupModCache(arg, *flgModCache)
+}
+
+// documentCached writes the documentation of the package p in the module cache to standard output, or
+// to the file named by -o in the current directory. The import path, source link prefix and git ref
+// come from the cache, unless -import, -replace or -gitref are given.
+func documentCached(p *godoc2md.CachedPackage, config *godoc2md.Config) error {
+	if *flgIndex != "" || *flgDOT != "" || *flgSite != "" || *flgSplit || *flgInject != "" || *flgCheck || *flgLint || *flgCheckSnippets || *flgVerifyExamples || *flgSince {
+		return fmt.Errorf("%s@%s: only -o can be used with a package from the module cache", p.ImportPath, p.Version)
+	}
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	imp, replace, ref := config.Import, config.Replace, config.GitRef
+	p.Configure(config)
+	if set["import"] {
+		config.Import, config.SubPackage = imp, ""
+	}
+	if set["replace"] {
+		config.Replace = replace
+	}
+	if set["gitref"] {
+		config.GitRef = ref
+	}
+
+	if *flgOut == "" {
+		return godoc2md.TransformCached(os.Stdout, p, config)
+	}
+	buf := &bytes.Buffer{}
+	if err := godoc2md.TransformCached(buf, p, config); err != nil {
+		return err
+	}
+	return os.WriteFile(*flgOut, buf.Bytes(), 0644)
+}
+
+// hasGoFiles returns true if the directory p contains Go files.
+func hasGoFiles(p string) bool {
+	matches, _ := filepath.Glob(filepath.Join(p, "*.go"))
+	return len(matches) > 0
+}
+
+// index writes the module index and the DOT import graph of the packages in root to the files
+// named by file and dot, either may be empty. The DOT graph is computed with opts, also when the
+// index has no graph.
+func index(root string, config *godoc2md.Config, opts godoc2md.GraphOptions, file, dot string) error {
+	if file != "" {
+		buf := &bytes.Buffer{}
+		if err := godoc2md.ModuleIndex(buf, root, config); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(root, file), buf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	if dot == "" {
+		return nil
+	}
+	g, err := godoc2md.ImportGraph(root, opts, config)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	if err := g.DOT(buf, opts.Highlight); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(root, dot), buf.Bytes(), 0644)
 }
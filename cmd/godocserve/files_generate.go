@@ -6,6 +6,7 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -123,6 +124,15 @@ func transform(repo, branch, vanity string) error {
 		SrcLinkHashFormat: "#L%d",
 	}
 
+	// Documentation coverage of each package, summed into the repo's coverage.
+	var reports []*godoc2md.LintReport
+
+	// Nested modules (tools/, v2/, ...) have their own import paths.
+	mods, err := godoc2md.FindModules(tmpdir, imp)
+	if err != nil {
+		return err
+	}
+
 	err = filepath.Walk(tmpdir,
 		func(p string, info os.FileInfo, err error) error {
 			if err != nil {
@@ -136,14 +146,20 @@ func transform(repo, branch, vanity string) error {
 				return nil
 			}
 			imptmp := config.Import
-			defer func() { config.Import = imptmp; config.SubPackage = "" }()
+			defer func() { config.Import = imptmp; config.SubPackage = ""; config.Repo = "" }()
 			if rel != "" && rel != "." {
 				if !checkForGoFiles(p) { // no go files, skip
 					log.Printf("%q, no Go files in %s, skipping", repo, p)
 					return nil
 				}
-				config.Import += "/" + rel
-				config.SubPackage = rel
+			}
+			mods.Configure(rel, config)
+
+			// If there is a README.md add that too, under a # README section, the docs will then follow under a # Documentation section.
+			readmebuf, rerr := os.ReadFile(path.Join(p, "README.md"))
+			config.HeadingOffset = 0
+			if rerr == nil {
+				config.HeadingOffset = 1 // fit under # Documentation
 			}
 
 			gobuf := &bytes.Buffer{}
@@ -154,8 +170,7 @@ func transform(repo, branch, vanity string) error {
 			}
 
 			rbuf := &bytes.Buffer{}
-			// If there is a README.md add that too, under a # README section, the docs will then follow under a # Documentation section.
-			if readmebuf, err := os.ReadFile(path.Join(p, "README.md")); err == nil {
+			if rerr == nil {
 				rbuf.WriteString("# README\n\n")
 
 				if gobuf.Len() > 10 { // there is go code docs, link to that.
@@ -164,6 +179,13 @@ func transform(repo, branch, vanity string) error {
 				rbuf.Write(readmebuf)
 			}
 
+			report, lerr := godoc2md.Lint(p, config)
+			if lerr != nil {
+				log.Printf("%q, failed to compute documentation coverage of %s: %v", repo, p, lerr)
+			} else {
+				reports = append(reports, report)
+			}
+
 			empty := checkForDocs(gobuf.Bytes())
 			if empty && rbuf.Len() == 0 { // bit of a cop out, but this means "no docs found", only return if also no readme
 				log.Printf("%q, no docs and no README.md in %s, skipping", repo, p)
@@ -191,10 +213,35 @@ func transform(repo, branch, vanity string) error {
 				log.Printf("%q, failed to write markdown %q, for %s: %v", repo, readme, err)
 			}
 			log.Printf("%q, wrote markdown into %q", repo, readme)
+
+			if report != nil {
+				report.Dir = "" // path in the temporary clone, means nothing when serving
+				stats := path.Join(path.Dir(readme), "stats.json")
+				if err := writeJSON(stats, report); err != nil {
+					log.Printf("%q, failed to write stats %q: %v", repo, stats, err)
+				}
+			}
 			return nil
 
 		})
-	return err
+	if err != nil {
+		return err
+	}
+
+	total := path.Join("content", imp, "repo.json")
+	if err := mkdirAll(path.Dir(total)); err != nil {
+		return err
+	}
+	return writeJSON(total, godoc2md.LintTotal(imp, reports))
+}
+
+// writeJSON writes v as JSON to the file p.
+func writeJSON(p string, v interface{}) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, buf, 0666)
 }
 
 // checkForGoFiles returns true when there are files in p with a .go extension.
@@ -214,13 +261,13 @@ func checkForGoFiles(p string) bool {
 	return false
 }
 
-// checkForDocs check if bug actually has content, if we index go binaries that don't
-// export anything we end up with almost empty file that only has 1 line: the import path: "> importpath"
+// checkForDocs check if bug actually has content, if we index go binaries that don't have a package
+// comment nor flags we end up with almost empty file that only has 1 line: the import path: "> importpath"
 func checkForDocs(buf []byte) bool {
 	stripped := bytes.TrimSpace(buf)
 	if len(stripped) == 0 {
 		return true
 	}
-	// if remaining line start with '>' we consider it empty
-	return bytes.HasPrefix(stripped, []byte("> "))
+	// if the only line starts with '>' we consider it empty
+	return bytes.HasPrefix(stripped, []byte("> ")) && !bytes.Contains(stripped, []byte("\n"))
 }
@@ -36,7 +36,8 @@ func (s searchContext) searchHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	FuncMap := template.FuncMap{
-		"linkify": linkify,
+		"linkify":  linkify,
+		"statsify": statsify,
 	}
 
 	type tmplContext struct {
@@ -108,7 +109,7 @@ func renderHandler(w http.ResponseWriter, r *http.Request) {
 		return
 
 	}
-	data, err = htmlify(data, title)
+	data, err = htmlify(data, title, statsHTML(title))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -119,7 +120,7 @@ func renderHandler(w http.ResponseWriter, r *http.Request) {
 
 func pathForReadme(p string) string { return filepath.Join("content", p+"/README.md") }
 
-func htmlify(buf []byte, title string) ([]byte, error) {
+func htmlify(buf []byte, title string, stats []byte) ([]byte, error) {
 	p := parser.NewWithExtensions(mparser.Extensions)
 	doc := markdown.Parse(buf, p)
 
@@ -128,7 +129,7 @@ func htmlify(buf []byte, title string) ([]byte, error) {
 	r := html.NewRenderer(opts)
 	buf = markdown.Render(doc, r)
 
-	buf = append(header(title), buf...)
+	buf = append(append(header(title), stats...), buf...)
 	buf = append(buf, footer()...)
 
 	return buf, nil
@@ -56,6 +56,8 @@ func main() {
 
 	r := mux.NewRouter()
 	r.PathPrefix("/assets").Handler(handlers.LoggingHandler(os.Stdout, http.FileServer(http.FS(content))))
+	r.PathPrefix("/badge/").Handler(handlers.LoggingHandler(os.Stdout, http.HandlerFunc(badgeHandler)))
+	r.Path("/coverage").Handler(handlers.LoggingHandler(os.Stdout, http.HandlerFunc(coverageHandler)))
 	r.PathPrefix("/g").Handler(handlers.LoggingHandler(os.Stdout, http.HandlerFunc(renderHandler)))
 	r.PathPrefix("/").Handler(handlers.LoggingHandler(os.Stdout, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		s := searchContext{
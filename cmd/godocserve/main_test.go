@@ -1,6 +1,12 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/miekg/godoc2md"
+)
 
 func TestLinkify(t *testing.T) {
 	link := linkify("content/github.com/miekg/dns/README.md")
@@ -8,3 +14,17 @@ func TestLinkify(t *testing.T) {
 		t.Errorf("failed to convert link correctly with linkify, got %s", link)
 	}
 }
+
+func TestBadge(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := badge(buf, &godoc2md.LintReport{Coverage: 92.4}); err != nil {
+		t.Fatal(err)
+	}
+	svg := buf.String()
+	if !strings.Contains(svg, ">92%</text>") {
+		t.Errorf("expected coverage 92%% in badge, got %s", svg)
+	}
+	if !strings.Contains(svg, `fill="#4c1"`) {
+		t.Errorf("expected green badge, got %s", svg)
+	}
+}
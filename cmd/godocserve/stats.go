@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/miekg/godoc2md"
+)
+
+// readStats returns the documentation coverage stored by files_generate.go for the import path imp in
+// the file name: "stats.json" for the package, next to its README.md, or "repo.json" for the whole repo.
+func readStats(imp, name string) (*godoc2md.LintReport, error) {
+	data, err := content.ReadFile(path.Join("content", imp, name))
+	if err != nil {
+		return nil, err
+	}
+	r := &godoc2md.LintReport{}
+	return r, json.Unmarshal(data, r)
+}
+
+// statsify returns the coverage of the package whose README.md is p, e.g.
+// "content/github.com/miekg/dns/README.md", as a short string. It returns the empty string when there are
+// no stats.
+func statsify(p string) string {
+	r, err := readStats(path.Dir(strings.TrimPrefix(p, "content/")), "stats.json")
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%.0f%% documented", r.Coverage)
+}
+
+// statsHTML returns the HTML shown above the documentation of the package imp: its badge, the coverage
+// and the number of symbols and examples. Imp is escaped, as it comes from the request.
+func statsHTML(imp string) []byte {
+	r, err := readStats(imp, "stats.json")
+	if err != nil {
+		return nil
+	}
+	return []byte(fmt.Sprintf(`<p class="stats"><img src="/badge/%s.svg" alt="doc coverage"> %d of %d exported symbols documented, %d examples. <a href="/coverage">Ranking</a>.</p>`+"\n",
+		html.EscapeString(imp), r.Documented, r.Symbols, r.Examples))
+}
+
+// badgeColor returns the color of the badge for coverage.
+func badgeColor(coverage float64) string {
+	switch {
+	case coverage >= 90:
+		return "#4c1"
+	case coverage >= 75:
+		return "#a4a61d"
+	case coverage >= 50:
+		return "#dfb317"
+	}
+	return "#e05d44"
+}
+
+const badgeTmpl = `<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="20" role="img" aria-label="godoc: {{.Value}}">
+<title>godoc: {{.Value}}</title>
+<rect width="48" height="20" fill="#555"/>
+<rect x="48" width="{{.ValueWidth}}" height="20" fill="{{.Color}}"/>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="24" y="14">godoc</text>
+<text x="{{.ValueX}}" y="14">{{.Value}}</text>
+</g>
+</svg>
+`
+
+// badge writes the SVG badge showing the documentation coverage r to buf.
+func badge(buf *bytes.Buffer, r *godoc2md.LintReport) error {
+	value := fmt.Sprintf("%.0f%%", r.Coverage)
+	valueWidth := 8 + 7*len(value)
+	ctx := struct {
+		Width, ValueWidth, ValueX int
+		Value, Color              string
+	}{48 + valueWidth, valueWidth, 48 + valueWidth/2, value, badgeColor(r.Coverage)}
+	return template.Must(template.New("badge").Parse(badgeTmpl)).Execute(buf, ctx)
+}
+
+// badgeHandler serves /badge/<import path>.svg, the documentation coverage of a package as a badge. For
+// the import path of a repo the coverage of the whole repo is shown.
+func badgeHandler(w http.ResponseWriter, r *http.Request) {
+	imp := strings.TrimPrefix(path.Clean(r.URL.Path), "/badge/")
+	if !strings.HasSuffix(imp, ".svg") {
+		http.Error(w, "badge must end in .svg", http.StatusNotFound)
+		return
+	}
+	imp = strings.TrimSuffix(imp, ".svg")
+	stats, err := readStats(imp, "repo.json")
+	if err != nil {
+		stats, err = readStats(imp, "stats.json")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	buf := &bytes.Buffer{}
+	if err := badge(buf, stats); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "max-age=3600")
+	w.Write(buf.Bytes())
+}
+
+// coverageHandler serves /coverage, the repos ranked by their documentation coverage.
+func coverageHandler(w http.ResponseWriter, r *http.Request) {
+	var repos []*godoc2md.LintReport
+	if err := fs.WalkDir(content, "content", func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil || d.Name() != "repo.json" {
+			return walkErr
+		}
+		stats, err := readStats(path.Dir(strings.TrimPrefix(p, "content/")), "repo.json")
+		if err != nil {
+			return err
+		}
+		repos = append(repos, stats)
+		return nil
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		if repos[i].Coverage != repos[j].Coverage {
+			return repos[i].Coverage > repos[j].Coverage
+		}
+		return repos[i].ImportPath < repos[j].ImportPath
+	})
+
+	covtmpl, err := template.New("coverage.tmpl").Funcs(template.FuncMap{"dirify": dirify}).ParseFS(content, "assets/coverage.tmpl")
+	if err != nil {
+		panic(err)
+	}
+	covbuf := &bytes.Buffer{}
+	if err := covtmpl.Execute(covbuf, repos); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	buf := append(header("Documentation coverage"), covbuf.Bytes()...)
+	buf = append(buf, footer()...)
+	w.Write(buf)
+}
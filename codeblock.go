@@ -0,0 +1,100 @@
+package godoc2md
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// codeLang returns the language of the code block in a comment, the lines still have their newlines.
+// Unless c.NoGuessLang is set, the language is guessed from the content: Go if it parses as Go
+// declarations or statements, shell if it shows $ prompts, or JSON or YAML. If nothing
+// matches, c.CodeLang is returned, which defaults to "text" when guessing.
+func (c *Config) codeLang(lines []string) string {
+	if c.NoGuessLang {
+		return c.CodeLang
+	}
+	if lang := guessLang(strings.Join(lines, "")); lang != "" {
+		return lang
+	}
+	if c.CodeLang != "" {
+		return c.CodeLang
+	}
+	return "text"
+}
+
+var (
+	promptRx   = regexp.MustCompile(`(?m)^\$ `)
+	yamlLineRx = regexp.MustCompile(`^\s*(- +)?([\w\-."']+:(\s.*)?|- .*|#.*)$`)
+	yamlKeyRx  = regexp.MustCompile(`^\s*(- +)?[\w\-."']+:(\s|$)`)
+)
+
+// guessLang returns the language of the code in src, or the empty string if it is not recognized.
+func guessLang(src string) string {
+	trimmed := strings.TrimSpace(src)
+	if trimmed == "" {
+		return ""
+	}
+	if promptRx.MatchString(src) {
+		return "sh"
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+	if isGo(src) {
+		return "go"
+	}
+	if isYAML(src) {
+		return "yaml"
+	}
+	return ""
+}
+
+// isGo returns true if src parses as Go declarations or statements. Statements must contain some
+// punctuation, otherwise most single words would count as Go.
+func isGo(src string) bool {
+	if _, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+src, 0); err == nil {
+		return true
+	}
+	if !strings.ContainsAny(src, "(){}=:;") {
+		return false
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "", "package p\nfunc _() {\n"+src+"\n}", 0); err == nil {
+		return true
+	}
+	return false
+}
+
+// isYAML returns true if every line of src looks like a YAML mapping, sequence item or comment,
+// and there is at least one key.
+func isYAML(src string) bool {
+	key := false
+	for _, line := range strings.Split(src, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !yamlLineRx.MatchString(line) {
+			return false
+		}
+		key = key || yamlKeyRx.MatchString(line)
+	}
+	return key
+}
+
+// codeFence returns a fence for the code block in lines that isn't closed by any of the lines.
+func codeFence(lines []string) string {
+	n := 3
+	for _, line := range lines {
+		line = strings.TrimLeft(line, " ")
+		i := 0
+		for i < len(line) && line[i] == '`' {
+			i++
+		}
+		if i >= n {
+			n = i + 1
+		}
+	}
+	return strings.Repeat("`", n)
+}
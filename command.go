@@ -0,0 +1,430 @@
+package godoc2md
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// Flag is a command line flag defined by a main package.
+type Flag struct {
+	Name      string // Name of the flag, without dashes.
+	Shorthand string // One letter shorthand, pflag only.
+	Type      string // Type of the flag: bool, string, duration, etc. Flags defined with Var, TextVar or Func have type "value".
+	Default   string // Default value as written in the source, string literals are quoted.
+	Usage     string // Usage string.
+	POSIX     bool   // Defined with pflag, the flag is used as --name.
+}
+
+// Command is the result of the static analysis of a main package.
+type Command struct {
+	Name        string     // Name of the flag set, empty for the command line.
+	Flags       []Flag     // Flags sorted by name.
+	Usage       string     // Text printed by the function assigned to flag.Usage, minus the flag defaults.
+	Subcommands []*Command // Flag sets created with NewFlagSet, these usually implement subcommands.
+}
+
+// flagPkgs are the import paths of the flag packages that are recognized.
+var flagPkgs = map[string]bool{
+	"flag":                   false,
+	"github.com/spf13/pflag": true,
+}
+
+// ParseCommand statically analyzes the main package in dir for flag and pflag definitions and for the
+// function assigned to flag.Usage. Flags defined through flag.CommandLine are found too, flag sets created
+// with NewFlagSet are returned as subcommands. Nothing is executed, so flags whose name isn't a constant are skipped.
+func ParseCommand(dir string) (*Command, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return parseCommand(osNameSpace(abs), abs)
+}
+
+// parseCommand parses the main package in the directory dir of ns, see ParseCommand.
+func parseCommand(ns vfs.NameSpace, dir string) (*Command, error) {
+	fset := token.NewFileSet()
+	_, files, err := parseDir(ns, dir, fset, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &cmdParser{fset: fset, funcs: map[string]*ast.FuncDecl{}, sets: map[string]*flagSet{}}
+	for _, f := range files {
+		for _, d := range f.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok && fd.Recv == nil {
+				c.funcs[fd.Name.Name] = fd
+			}
+		}
+	}
+	for _, f := range files {
+		c.pkgs = map[string]bool{}
+		for _, imp := range f.Imports {
+			p, _ := strconv.Unquote(imp.Path.Value)
+			posix, ok := flagPkgs[p]
+			if !ok {
+				continue
+			}
+			name := filepath.Base(p)
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			c.pkgs[name] = posix
+		}
+		if len(c.pkgs) > 0 {
+			ast.Inspect(f, c.inspect)
+		}
+	}
+	for _, cmd := range append([]*Command{&c.cmd}, c.cmd.Subcommands...) {
+		sort.SliceStable(cmd.Flags, func(i, j int) bool { return cmd.Flags[i].Name < cmd.Flags[j].Name })
+		cmd.Usage = strings.TrimSpace(cmd.Usage)
+	}
+	return &c.cmd, nil
+}
+
+type cmdParser struct {
+	fset  *token.FileSet
+	pkgs  map[string]bool // local name of the flag packages in the current file, true for pflag
+	funcs map[string]*ast.FuncDecl
+	sets  map[string]*flagSet // variables holding a flag set
+	cmd   Command
+}
+
+type flagSet struct {
+	posix bool // created with pflag
+	cmd   *Command
+}
+
+// flagSet returns the flag set x refers to: one of the flag packages, flag.CommandLine or a variable
+// holding a flag set. It returns nil if x is none of these.
+func (c *cmdParser) flagSet(x ast.Expr) *flagSet {
+	switch x := x.(type) {
+	case *ast.Ident:
+		if posix, ok := c.pkgs[x.Name]; ok {
+			return &flagSet{posix, &c.cmd}
+		}
+		return c.sets[x.Name]
+	case *ast.SelectorExpr: // flag.CommandLine
+		if id, ok := x.X.(*ast.Ident); ok && x.Sel.Name == "CommandLine" {
+			if posix, ok := c.pkgs[id.Name]; ok {
+				return &flagSet{posix, &c.cmd}
+			}
+		}
+	}
+	return nil
+}
+
+func (c *cmdParser) inspect(n ast.Node) bool {
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		for i, lhs := range n.Lhs {
+			if i >= len(n.Rhs) {
+				break
+			}
+			// fs := flag.NewFlagSet(...)
+			if id, ok := lhs.(*ast.Ident); ok {
+				if call, ok := n.Rhs[i].(*ast.CallExpr); ok {
+					if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "NewFlagSet" {
+						if fs := c.flagSet(sel.X); fs != nil {
+							name := id.Name
+							if len(call.Args) > 0 && c.str(call.Args[0]) != "" {
+								name = c.str(call.Args[0])
+							}
+							sub := &Command{Name: name}
+							c.cmd.Subcommands = append(c.cmd.Subcommands, sub)
+							c.sets[id.Name] = &flagSet{fs.posix, sub}
+						}
+					}
+				}
+			}
+			// flag.Usage = ...
+			if sel, ok := lhs.(*ast.SelectorExpr); ok && sel.Sel.Name == "Usage" {
+				if fs := c.flagSet(sel.X); fs != nil {
+					fs.cmd.Usage += c.usage(n.Rhs[i])
+				}
+			}
+		}
+	case *ast.CallExpr:
+		if sel, ok := n.Fun.(*ast.SelectorExpr); ok {
+			if fs := c.flagSet(sel.X); fs != nil {
+				if f, ok := c.flag(sel.Sel.Name, n.Args, fs.posix); ok {
+					fs.cmd.Flags = append(fs.cmd.Flags, f)
+				}
+			}
+		}
+	}
+	return true
+}
+
+// flagFunc describes the arguments of a function or method that defines a flag.
+type flagFunc struct {
+	typ   string // Type of the flag.
+	ptr   bool   // The first argument is the variable or Value the flag is stored in.
+	short bool   // The name is followed by a shorthand.
+	def   bool   // A default value precedes the usage string.
+	fn    bool   // The usage string is followed by a function called with the value.
+}
+
+// flagFuncs are the functions and methods of the flag package that define flags.
+var flagFuncs = newFlagFuncs(false, []string{"Bool", "Duration", "Float64", "Int", "Int64", "String", "Uint", "Uint64"}, map[string]flagFunc{
+	"Var":      {typ: "value", ptr: true},
+	"TextVar":  {typ: "value", ptr: true, def: true},
+	"Func":     {typ: "value", fn: true},
+	"BoolFunc": {typ: "bool", fn: true},
+})
+
+// pflagFuncs are the functions and methods of pflag that define flags.
+var pflagFuncs = newFlagFuncs(true, []string{
+	"Bool", "BoolSlice", "BytesBase64", "BytesHex", "Duration", "DurationSlice", "Float32", "Float32Slice",
+	"Float64", "Float64Slice", "Int", "Int8", "Int16", "Int32", "Int32Slice", "Int64", "Int64Slice", "IntSlice",
+	"IP", "IPMask", "IPNet", "IPSlice", "String", "StringArray", "StringSlice", "StringToInt", "StringToInt64",
+	"StringToString", "Uint", "Uint8", "Uint16", "Uint32", "Uint64", "UintSlice",
+}, map[string]flagFunc{
+	"Var":       {typ: "value", ptr: true},
+	"VarP":      {typ: "value", ptr: true, short: true},
+	"TextVar":   {typ: "value", ptr: true, def: true},
+	"TextVarP":  {typ: "value", ptr: true, short: true, def: true},
+	"Func":      {typ: "value", fn: true},
+	"FuncP":     {typ: "value", short: true, fn: true},
+	"BoolFunc":  {typ: "bool", fn: true},
+	"BoolFuncP": {typ: "bool", short: true, fn: true},
+	"Count":     {typ: "count"},
+	"CountP":    {typ: "count", short: true},
+	"CountVar":  {typ: "count", ptr: true},
+	"CountVarP": {typ: "count", ptr: true, short: true},
+})
+
+// newFlagFuncs adds to funcs the functions defining a flag of each of the kinds, T and TVar, and for
+// pflag also TP and TVarP. They take a default value and are named after the flag's type, e.g. IntSlice
+// defines an intSlice.
+func newFlagFuncs(posix bool, kinds []string, funcs map[string]flagFunc) map[string]flagFunc {
+	for _, k := range kinds {
+		typ := strings.ToLower(k[:1]) + k[1:]
+		if strings.HasPrefix(k, "IP") {
+			typ = "ip" + k[2:]
+		}
+		funcs[k] = flagFunc{typ: typ, def: true}
+		funcs[k+"Var"] = flagFunc{typ: typ, ptr: true, def: true}
+		if posix {
+			funcs[k+"P"] = flagFunc{typ: typ, short: true, def: true}
+			funcs[k+"VarP"] = flagFunc{typ: typ, ptr: true, short: true, def: true}
+		}
+	}
+	return funcs
+}
+
+// flag returns the flag defined by calling the function or method name with args. Only the functions in
+// flagFuncs, or pflagFuncs if posix is true, define flags.
+func (c *cmdParser) flag(name string, args []ast.Expr, posix bool) (Flag, bool) {
+	funcs := flagFuncs
+	if posix {
+		funcs = pflagFuncs
+	}
+	ff, ok := funcs[name]
+	if !ok {
+		return Flag{}, false
+	}
+	n := 2 // name, usage
+	for _, b := range []bool{ff.ptr, ff.short, ff.def, ff.fn} {
+		if b {
+			n++
+		}
+	}
+	if len(args) != n {
+		return Flag{}, false
+	}
+	if ff.ptr {
+		args = args[1:]
+	}
+
+	f := Flag{Type: ff.typ, POSIX: posix}
+	f.Name, args = c.str(args[0]), args[1:]
+	if ff.short {
+		f.Shorthand, args = c.str(args[0]), args[1:]
+	}
+	if ff.def {
+		f.Default, args = c.source(args[0]), args[1:]
+	}
+	f.Usage = c.str(args[0])
+	return f, f.Name != ""
+}
+
+// str returns the value of the string constant x, or the empty string if it's not a constant.
+func (c *cmdParser) str(x ast.Expr) string {
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		if s, err := strconv.Unquote(x.Value); err == nil {
+			return s
+		}
+	case *ast.BinaryExpr:
+		if x.Op == token.ADD {
+			if l, r := c.str(x.X), c.str(x.Y); l != "" && r != "" {
+				return l + r
+			}
+		}
+		return ""
+	case *ast.ParenExpr:
+		return c.str(x.X)
+	}
+	return ""
+}
+
+// source returns the source code of x.
+func (c *cmdParser) source(x ast.Expr) string {
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, c.fset, x); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// printFuncs are the functions whose string arguments end up in the usage text, the value is true if
+// the first argument is the writer.
+var printFuncs = map[string]bool{
+	"Fprintf": true, "Fprintln": true, "Fprint": true,
+	"Printf": false, "Println": false, "Print": false,
+	"print": false, "println": false,
+}
+
+// usage returns the text printed by the function in x, a function literal or the name of a function.
+func (c *cmdParser) usage(x ast.Expr) string {
+	var body *ast.BlockStmt
+	switch x := x.(type) {
+	case *ast.FuncLit:
+		body = x.Body
+	case *ast.Ident:
+		if fd, ok := c.funcs[x.Name]; ok {
+			body = fd.Body
+		}
+	}
+	if body == nil {
+		return ""
+	}
+	b := &strings.Builder{}
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		name := ""
+		switch fn := call.Fun.(type) {
+		case *ast.Ident:
+			name = fn.Name
+		case *ast.SelectorExpr:
+			name = fn.Sel.Name
+		}
+		writer, ok := printFuncs[name]
+		if !ok {
+			return true
+		}
+		args := call.Args
+		if writer && len(args) > 0 {
+			args = args[1:]
+		}
+		if strings.HasSuffix(name, "f") {
+			if len(args) > 0 {
+				b.WriteString(c.str(args[0]))
+			}
+			return false
+		}
+		var texts []string
+		for _, a := range args {
+			if s := c.str(a); s != "" {
+				texts = append(texts, s)
+			}
+		}
+		if strings.HasSuffix(name, "ln") {
+			fmt.Fprintln(b, strings.Join(texts, " "))
+		} else {
+			b.WriteString(strings.Join(texts, ""))
+		}
+		return false
+	})
+	return b.String()
+}
+
+// usageMd renders the command in the directory dir of ns as a markdown Usage section. It returns the
+// empty string if the command has neither flags nor a usage text.
+func usageMd(ns vfs.NameSpace, dir string) (string, error) {
+	cmd, err := parseCommand(ns, dir)
+	if err != nil {
+		return "", err
+	}
+	b := &strings.Builder{}
+	cmd.markdown(b)
+	for _, sub := range cmd.Subcommands {
+		if len(sub.Flags) > 0 || sub.Usage != "" {
+			fmt.Fprintf(b, "### %s {#pkg-usage-%s}\n\n", escapeMd(sub.Name, ctxHeading), strings.TrimPrefix(headingKey(sub.Name), "hdr-"))
+			sub.markdown(b)
+		}
+	}
+	if b.Len() == 0 {
+		return "", nil
+	}
+	return "## Usage {#pkg-usage}\n\n" + b.String(), nil
+}
+
+// markdown writes the usage text and a table of the flags of c to b.
+func (c *Command) markdown(b *strings.Builder) {
+	if c.Usage != "" {
+		fence := codeFence(strings.Split(c.Usage, "\n"))
+		fmt.Fprintf(b, "%s\n%s\n%s\n\n", fence, c.Usage, fence)
+	}
+	if len(c.Flags) == 0 {
+		return
+	}
+	b.WriteString("| Flag | Type | Default | Description |\n")
+	b.WriteString("|------|------|---------|-------------|\n")
+	for _, f := range c.Flags {
+		def := ""
+		if f.Default != "" {
+			def = codeSpan(f.Default)
+		}
+		fmt.Fprintf(b, "| %s | %s | %s | %s |\n", codeSpan(f.flag()), f.Type, def, escapeMd(f.Usage, ctxTable))
+	}
+	b.WriteString("\n")
+}
+
+// flag returns the flag as it is given on the command line, with its shorthand: "-v, --verbose".
+func (f Flag) flag() string {
+	name := "-" + f.Name
+	if f.POSIX {
+		name = "-" + name
+	}
+	if f.Shorthand != "" {
+		name = "-" + f.Shorthand + ", " + name
+	}
+	return name
+}
+
+// codeSpan returns s as inline code in a table cell.
+func codeSpan(s string) string {
+	s = strings.Replace(strings.Replace(s, "\n", " ", -1), "|", `\|`, -1)
+	n := 0
+	for i := 0; i < len(s); {
+		j := i
+		for j < len(s) && s[j] == '`' {
+			j++
+		}
+		if j-i > n {
+			n = j - i
+		}
+		if j == i {
+			j++
+		}
+		i = j
+	}
+	fence := strings.Repeat("`", n+1)
+	if n > 0 {
+		return fence + " " + s + " " + fence
+	}
+	return fence + s + fence
+}
@@ -8,7 +8,6 @@ import (
 	"io"
 	"regexp"
 	"strings"
-	"text/template" // for HTMLEscape
 	"unicode"
 	"unicode/utf8"
 )
@@ -18,60 +17,70 @@ const (
 	identRx = `[a-zA-Z_][a-zA-Z_0-9]*`
 
 	// Regexp for URLs
-	protocol = `(https?|ftp|file|gopher|mailto|news|nntp|telnet|wais|prospero):`
-	hostPart = `[a-zA-Z0-9_@\-]+`
-	filePart = `[a-zA-Z0-9_?%#~&/\-+=]+`
-	urlRx    = protocol + `//` + // http://
+	protocols = `https?|ftp|file|gopher|mailto|news|nntp|telnet|wais|prospero`
+	hostPart  = `[a-zA-Z0-9_@\-]+`
+	filePart  = `[a-zA-Z0-9_?%#~&/\-+=()!*',;@$]+`
+	urlRx     = `//` + // http://
 		hostPart + `([.:]` + hostPart + `)*/?` + // //www.google.com:8080/
 		filePart + `([:.,]` + filePart + `)*`
 )
 
-var matchRx = regexp.MustCompile(`(` + urlRx + `)|(` + identRx + `)`)
+var urlMatchRx = regexp.MustCompile(`(` + protocols + `):` + urlRx)
 
-var (
-	htmlA    = []byte(`<a href="`)
-	htmlAq   = []byte(`">`)
-	htmlEnda = []byte("</a>")
+// urlRegexp returns the regexp matching URLs, this includes the schemes from c.URLSchemes. It
+// returns nil if linkification is turned off.
+func (c *Config) urlRegexp() *regexp.Regexp {
+	if c.NoLinkify {
+		return nil
+	}
+	if len(c.URLSchemes) == 0 {
+		return urlMatchRx
+	}
+	schemes := protocols
+	for _, s := range c.URLSchemes {
+		schemes += "|" + regexp.QuoteMeta(strings.TrimSuffix(s, ":"))
+	}
+	return regexp.MustCompile(`(` + schemes + `):` + urlRx)
+}
 
-	mdPre     = []byte("\t")
+// trimURL removes trailing punctuation from url and closing parentheses that have no opening one, these
+// are more likely part of the sentence than of the URL.
+func trimURL(url string) string {
+	for url != "" {
+		switch c := url[len(url)-1]; {
+		case strings.IndexByte(".,:;?!'*", c) >= 0:
+		case c == ')' && strings.Count(url, "(") < strings.Count(url, ")"):
+		default:
+			return url
+		}
+		url = url[:len(url)-1]
+	}
+	return url
+}
+
+var (
 	mdNewline = []byte("\n")
 	mdH3      = []byte("### ")
 	mdItem    = []byte("* ")
 )
 
-// Emphasize and escape a line of text for HTML. URLs are converted into links.
-func emphasize(w io.Writer, line string) {
-	for {
-		m := matchRx.FindStringSubmatchIndex(line)
-		if m == nil {
-			break
-		}
-		// m >= 6 (two parenthesized sub-regexps in matchRx, 1st one is urlRx)
-
-		// write text before match
-		w.Write([]byte(line[0:m[0]]))
-
-		// analyze match
-		match := line[m[0]:m[1]]
-
-		// if URL then write as link
-		if m[2] >= 0 {
-			w.Write(htmlA)
-			template.HTMLEscape(w, []byte(match))
-			w.Write(htmlAq)
-		}
-
-		// write match
-		w.Write([]byte(match))
-
-		if m[2] >= 0 {
-			w.Write(htmlEnda)
-		}
-
-		// advance
-		line = line[m[1]:]
+// Emphasize and escape a line of text for markdown. URLs matched by urlRx are converted into autolinks,
+// doc links, [Name], that links can resolve are converted into markdown links.
+func emphasize(w io.Writer, line string, urlRx *regexp.Regexp, links *docLinks) {
+	var urls [][]int
+	if urlRx != nil {
+		urls = urlRx.FindAllStringIndex(line, -1)
 	}
-	w.Write([]byte(line))
+	io.WriteString(w, escapeMdFunc(line, ctxPara, func(i int) (string, int) {
+		s, n := inline(line, i, urls, links)
+		switch {
+		case n == 0:
+			return "", 0
+		case s.Text == s.URL:
+			return "<" + s.URL + ">", n
+		}
+		return "[" + escapeMd(s.Text, ctxLink) + "](" + s.URL + ")", n
+	}))
 }
 
 func indentLen(s string) int {
@@ -178,12 +187,7 @@ const (
 type block struct {
 	op    op
 	lines []string
-}
-
-var nonAlphaNumRx = regexp.MustCompile(`[^a-zA-Z0-9]`)
-
-func anchorID(line string) string {
-	return "hdr_" + strings.ToLower(nonAlphaNumRx.ReplaceAllString(line, "_"))
+	line  int // index of the first line in the text, for opPre
 }
 
 // toMd converts comment text to formatted Markdown.
@@ -198,25 +202,34 @@ func anchorID(line string) string {
 // begins with a capital letter, and contains no punctuation
 // is formatted as a heading.
 //
-// A span of indented lines is converted into a <pre> block,
-// with the common indent prefix removed.
+// A span of indented lines is converted into a fenced code block,
+// with the common indent prefix removed. See Config.codeLang for the
+// language that is given to the block.
 //
-// URLs in the comment text are converted into links.
-func toMd(w io.Writer, text string) {
+// URLs in the comment text are converted into links, unless config.NoLinkify
+// is set. Doc links are resolved with links, which may be nil.
+func toMd(w io.Writer, text string, config *Config, links *docLinks) {
+	urlRx := config.urlRegexp()
+
 	// range over the blocks to fetch the headers to create a table of contents
+	begin, end := config.details("Contents")
 	closeToc := func() {}
 	for _, b := range blocks(text) {
-		if b.op == opHead {
-			closeToc = func() { w.Write(mdNewline); w.Write(mdNewline) }
+		if b.op == opHead && config.toc(1) {
+			if begin != "" {
+				io.WriteString(w, begin)
+				begin = ""
+			}
+			closeToc = func() { w.Write(mdNewline); io.WriteString(w, end); w.Write(mdNewline) }
 			// [title](#link)
 			w.Write(mdItem)
 			w.Write([]byte("["))
 			id := ""
 			for _, line := range b.lines {
 				if id == "" {
-					id = anchorID(line)
+					id = headingKey(line)
 				}
-				w.Write([]byte(line))
+				io.WriteString(w, escapeMd(line, ctxLink))
 			}
 			w.Write([]byte("]"))
 			w.Write([]byte("(#"))
@@ -231,7 +244,7 @@ func toMd(w io.Writer, text string) {
 		switch b.op {
 		case opPara:
 			for _, line := range b.lines {
-				emphasize(w, line)
+				emphasize(w, line, urlRx, links)
 			}
 			w.Write(mdNewline) // trailing newline to emulate </p>
 		case opHead:
@@ -239,20 +252,29 @@ func toMd(w io.Writer, text string) {
 			id := ""
 			for _, line := range b.lines {
 				if id == "" {
-					id = anchorID(line)
+					id = headingKey(line)
 				}
-				w.Write([]byte(line))
+				io.WriteString(w, escapeMd(line, ctxHeading))
 				w.Write([]byte(" {#"))
 				io.WriteString(w, id)
 				w.Write([]byte("}"))
 			}
 			w.Write(mdNewline)
 		case opPre:
+			fence := codeFence(b.lines)
+			w.Write(mdNewline)
+			io.WriteString(w, fence)
+			if lang := config.codeLang(b.lines); lang != "" {
+				io.WriteString(w, " "+lang)
+			}
 			w.Write(mdNewline)
 			for _, line := range b.lines {
-				w.Write(mdPre)
-				emphasize(w, line)
+				io.WriteString(w, line) // code is not escaped
+			}
+			if last := b.lines[len(b.lines)-1]; !strings.HasSuffix(last, "\n") {
+				w.Write(mdNewline)
 			}
+			io.WriteString(w, fence)
 			w.Write(mdNewline)
 		}
 	}
@@ -269,7 +291,7 @@ func blocks(text string) []block {
 
 	close := func() {
 		if para != nil {
-			out = append(out, block{opPara, para})
+			out = append(out, block{op: opPara, lines: para})
 			para = nil
 		}
 	}
@@ -299,12 +321,13 @@ func blocks(text string) []block {
 				j--
 			}
 			pre := lines[i:j]
+			start := i
 			i = j
 
 			unindent(pre)
 
 			// put those lines in a pre block
-			out = append(out, block{opPre, pre})
+			out = append(out, block{op: opPre, lines: pre, line: start})
 			lastWasHeading = false
 			continue
 		}
@@ -316,7 +339,7 @@ func blocks(text string) []block {
 			// might be a heading.
 			if head := heading(line); head != "" {
 				close()
-				out = append(out, block{opHead, []string{head}})
+				out = append(out, block{op: opHead, lines: []string{head}})
 				i += 2
 				lastWasHeading = true
 				continue
@@ -0,0 +1,196 @@
+package godoc2md
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// edit is a single line in an edit script, op is one of ' ', '-' or '+'.
+type edit struct {
+	op   byte
+	line string
+}
+
+// unifiedDiff returns a unified diff between a and b. The empty string is returned if they are
+// identical.
+func unifiedDiff(aName, bName string, a, b []byte) string {
+	if bytes.Equal(a, b) {
+		return ""
+	}
+	edits := diffLines(splitLines(a), splitLines(b))
+
+	const context = 3
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "--- %s\n+++ %s\n", aName, bName)
+
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			i++
+			continue
+		}
+		// start of a hunk, include up to context lines before it
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(edits) {
+			if edits[end].op != ' ' {
+				end++
+				continue
+			}
+			// run of unchanged lines, see if the hunk continues after it
+			j := end
+			for j < len(edits) && edits[j].op == ' ' {
+				j++
+			}
+			if j == len(edits) || j-end > 2*context {
+				end += context
+				if end > len(edits) {
+					end = len(edits)
+				}
+				break
+			}
+			end = j
+		}
+
+		aStart, bStart := 1, 1
+		for _, e := range edits[:start] {
+			if e.op != '+' {
+				aStart++
+			}
+			if e.op != '-' {
+				bStart++
+			}
+		}
+		aLen, bLen := 0, 0
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				aLen++
+			}
+			if e.op != '-' {
+				bLen++
+			}
+		}
+		fmt.Fprintf(buf, "@@ -%s +%s @@\n", hunkRange(aStart, aLen), hunkRange(bStart, bLen))
+		for _, e := range edits[start:end] {
+			buf.WriteByte(e.op)
+			buf.WriteString(e.line)
+			buf.WriteByte('\n')
+		}
+		i = end
+	}
+	return buf.String()
+}
+
+func hunkRange(start, n int) string {
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, n)
+}
+
+// noNewline marks the last line of a file that doesn't end in a newline. It is part of the line, so
+// the line differs from the same line with a newline, and is printed after it like diff does.
+const noNewline = "\n\\ No newline at end of file"
+
+func splitLines(b []byte) []string {
+	s := strings.TrimSuffix(string(b), "\n")
+	if s == "" && len(b) == 0 {
+		return nil
+	}
+	lines := strings.Split(s, "\n")
+	if len(s) == len(b) {
+		lines[len(lines)-1] += noNewline
+	}
+	return lines
+}
+
+// diffLines returns the edit script that turns a into b. It uses the Myers algorithm, see "An
+// O(ND) Difference Algorithm and Its Variations".
+func diffLines(a, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	v := make([]int, 2*max+2)
+	var trace []snapshot
+
+	for d := 0; d <= max; d++ {
+		// only the diagonals -d-1 .. d+1 are needed when backtracking
+		lo, hi := max-d-1, max+d+2
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > len(v) {
+			hi = len(v)
+		}
+		trace = append(trace, snapshot{lo - max, append([]int(nil), v[lo:hi]...)})
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, d, max)
+			}
+		}
+	}
+	return nil
+}
+
+// snapshot holds the furthest reaching x values for the diagonals starting at k.
+type snapshot struct {
+	k int
+	v []int
+}
+
+func (s snapshot) at(k int) int { return s.v[k-s.k] }
+
+func backtrack(trace []snapshot, a, b []string, d, max int) []edit {
+	x, y := len(a), len(b)
+	var edits []edit
+	for ; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v.at(k-1) < v.at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v.at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{' ', a[x]})
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, edit{'+', b[y]})
+		} else {
+			x--
+			edits = append(edits, edit{'-', a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		edits = append(edits, edit{' ', a[x]})
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
@@ -0,0 +1,136 @@
+package godoc2md
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/godoc"
+)
+
+// DocLinkFormat is the format used for doc links to other packages, the import path and the
+// symbol (which may be empty) are filled in.
+var DocLinkFormat = "https://pkg.go.dev/%s#%s"
+
+// docLinks resolves doc links in comments, [Name], [Type.Method], [pkg.Name] or [path/to/pkg.Name].
+type docLinks struct {
+	pkg     string            // name of the package
+	keys    map[string]string // symbol to the key of the heading where it is documented
+	imports map[string]string // package name to import path
+}
+
+var majorRx = regexp.MustCompile(`^v[0-9]+$`)
+
+// newDocLinks returns the doc links for the package in info, info may be nil.
+func newDocLinks(info *godoc.PageInfo) *docLinks {
+	d := &docLinks{keys: map[string]string{}, imports: map[string]string{}}
+	if info == nil || info.PDoc == nil {
+		return d
+	}
+	pdoc := info.PDoc
+	d.pkg = pdoc.Name
+	for _, imp := range pdoc.Imports {
+		name := path.Base(imp)
+		if majorRx.MatchString(name) && strings.Contains(imp, "/") {
+			name = path.Base(path.Dir(imp))
+		}
+		if i := strings.Index(name, ".v"); i > 0 { // gopkg.in/yaml.v2
+			name = name[:i]
+		}
+		d.imports[strings.Replace(name, "-", "_", -1)] = imp
+	}
+	for _, c := range pdoc.Consts {
+		for _, n := range c.Names {
+			d.keys[n] = "pkg-constants"
+		}
+	}
+	for _, v := range pdoc.Vars {
+		for _, n := range v.Names {
+			d.keys[n] = "pkg-variables"
+		}
+	}
+	for _, f := range pdoc.Funcs {
+		d.keys[f.Name] = f.Name
+	}
+	for _, t := range pdoc.Types {
+		d.keys[t.Name] = t.Name
+		for _, c := range t.Consts {
+			for _, n := range c.Names {
+				d.keys[n] = t.Name
+			}
+		}
+		for _, v := range t.Vars {
+			for _, n := range v.Names {
+				d.keys[n] = t.Name
+			}
+		}
+		for _, f := range t.Funcs {
+			d.keys[f.Name] = f.Name
+		}
+		for _, m := range t.Methods {
+			d.keys[t.Name+"."+m.Name] = t.Name + "." + m.Name
+		}
+	}
+	return d
+}
+
+// url returns the URL for the doc link text, the empty string is returned when it can't be resolved.
+// Links to symbols in this package point to the key of the heading, these are resolved by the anchor engine.
+func (d *docLinks) url(text string) string {
+	if d == nil {
+		return ""
+	}
+	text = strings.TrimPrefix(text, "*")
+
+	// [path/to/pkg.Name]
+	if i := strings.LastIndex(text, "/"); i > 0 {
+		pkg, sym := text, ""
+		if j := strings.Index(text[i:], "."); j > 0 {
+			pkg, sym = text[:i+j], text[i+j+1:]
+		}
+		// like go/doc, the first element of a non standard library import path must contain a dot
+		if !strings.Contains(strings.SplitN(pkg, "/", 2)[0], ".") && !isStd(pkg) {
+			return ""
+		}
+		return external(pkg, sym)
+	}
+
+	parts := strings.SplitN(text, ".", 2)
+	if parts[0] == d.pkg && len(parts) == 2 {
+		parts = strings.SplitN(parts[1], ".", 2)
+		text = strings.Join(parts, ".")
+	}
+	if key, ok := d.keys[text]; ok {
+		return "#" + key
+	}
+	if _, ok := d.keys[parts[0]]; ok { // [Type.Field]
+		return "#" + d.keys[parts[0]]
+	}
+
+	sym := ""
+	if len(parts) == 2 {
+		sym = parts[1]
+	}
+	if imp, ok := d.imports[parts[0]]; ok {
+		return external(imp, sym)
+	}
+	if isStd(parts[0]) {
+		return external(parts[0], sym)
+	}
+	return ""
+}
+
+func external(imp, sym string) string {
+	return strings.TrimSuffix(strings.Replace(strings.Replace(DocLinkFormat, "%s", imp, 1), "%s", sym, 1), "#")
+}
+
+// isStd returns true if pkg is a standard library package.
+func isStd(pkg string) bool {
+	if pkg == "" || strings.ToLower(pkg) != pkg {
+		return false
+	}
+	fi, err := os.Stat(filepath.Join(defaultContext().GOROOT, "src", pkg))
+	return err == nil && fi.IsDir()
+}
@@ -0,0 +1,143 @@
+package godoc2md
+
+import (
+	"regexp"
+	"strings"
+)
+
+// mdContext is the place in a markdown document where text is written, this determines what needs
+// to be escaped.
+type mdContext int
+
+const (
+	ctxPara    mdContext = iota // text in a paragraph, each line may start a block
+	ctxHeading                  // text of a heading
+	ctxTable                    // text in a table cell
+	ctxLink                     // text of a link: [text](url)
+)
+
+// mdSpecial are the characters that are escaped wherever they occur. This includes the inline syntax
+// of mmark's extensions: sub- and superscript, attributes and includes.
+const mdSpecial = "\\`*_[]<|~^{}"
+
+var (
+	entityRx  = regexp.MustCompile(`^&(#[0-9]+|#[xX][0-9a-fA-F]+|[a-zA-Z][a-zA-Z0-9]*);`)
+	docLinkRx = regexp.MustCompile(`^\[(?:\*?(?:[a-zA-Z0-9_\-./]+\.)?` + identRx + `(?:\.` + identRx + `)?|[a-zA-Z0-9_\-.]+(?:/[a-zA-Z0-9_\-.]+)+)\]`)
+)
+
+// escapeMd escapes text so it is rendered literally in context ctx. Doc links, [Name], are kept as
+// is, as these are intentional.
+func escapeMd(text string, ctx mdContext) string { return escapeMdFunc(text, ctx, nil) }
+
+// escapeMdFunc is escapeMd, but for each position i in text verbatim is called first, if not nil. If
+// it returns n > 0, s is written instead of the next n bytes of text. This is used for URLs and code.
+func escapeMdFunc(text string, ctx mdContext, verbatim func(i int) (s string, n int)) string {
+	if ctx == ctxTable {
+		text = strings.Replace(text, "\n", " ", -1)
+	}
+	// $ can't be escaped with a backslash, use an entity when there is a chance of it being inline math
+	dollar := strings.Count(text, "$") > 1
+	var b strings.Builder
+	start := ctx == ctxPara // at the start of a line
+	for i := 0; i < len(text); i++ {
+		if verbatim != nil {
+			if v, n := verbatim(i); n > 0 {
+				b.WriteString(v)
+				i += n - 1
+				start = false
+				continue
+			}
+		}
+		c := text[i]
+		if start {
+			if c == ' ' || c == '\t' {
+				b.WriteByte(c)
+				continue
+			}
+			start = false
+			if c == '=' {
+				// setext heading underline, = can't be escaped with a backslash
+				b.WriteString("&#61;")
+				continue
+			}
+			if n := blockStart(text[i:]); n >= 0 {
+				b.WriteString(text[i : i+n])
+				b.WriteByte('\\')
+				b.WriteByte(text[i+n])
+				i += n
+				continue
+			}
+		}
+
+		switch {
+		case c == '\n':
+			start = ctx == ctxPara
+		case c == '[' && ctx == ctxPara:
+			// a doc link must not be mistaken for a markdown link: [Name](url), [Name] [ref] or [Name]: url
+			if m := docLinkRx.FindString(text[i:]); m != "" && !linkFollows(text[i+len(m):]) {
+				b.WriteString(m)
+				i += len(m) - 1
+				continue
+			}
+			b.WriteByte('\\')
+		case c == '{' && ctx == ctxHeading:
+			// the parser looks for heading IDs, {#id}, without regard for escapes
+			b.WriteString("&#123;")
+			continue
+		case c == '\\' && ctx == ctxLink:
+			// the parser takes \] as the end of the link text, as it doesn't see the first backslash
+			b.WriteString("&#92;")
+			continue
+		case c == ']' && strings.HasPrefix(text[i+1:], ":"):
+			// the parser sees [text\]: as a link reference definition
+			b.WriteString("&#93;")
+			continue
+		case strings.IndexByte(mdSpecial, c) >= 0:
+			b.WriteByte('\\')
+		case c == '$' && dollar:
+			b.WriteString("&#36;")
+			continue
+		case c == '&' && entityRx.MatchString(text[i:]):
+			b.WriteByte('\\')
+		case c == '(' && i+1 < len(text) && (text[i+1] == '!' || text[i+1] == '#' || text[i+1] == '@'):
+			// mmark's index, cross reference and citation syntax
+			b.WriteByte('\\')
+		case c == '#' && ctx == ctxHeading && strings.Trim(text[i:], "# ") == "":
+			// closing sequence of a heading
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// blockStart returns the offset of the character that must be escaped in s, because s starts
+// a block: a heading, list item, block quote, aside or definition. It returns -1 if nothing needs escaping.
+func blockStart(s string) int {
+	switch s[0] {
+	case '#', '-', '+', '>', ':':
+		return 0
+	}
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i > 0 && i < len(s) && (s[i] == '.' || s[i] == ')') {
+		return i
+	}
+	// mmark special headings: .# Abstract
+	if strings.HasPrefix(s, ".#") {
+		return 0
+	}
+	// mmark asides, notes and figures: A>, N>, F>.
+	if len(s) > 1 && s[1] == '>' && s[0] >= 'A' && s[0] <= 'Z' {
+		return 1
+	}
+	return -1
+}
+
+// linkFollows returns true if s, the text after a doc link, would turn the doc link into a markdown link.
+func linkFollows(s string) bool {
+	s = strings.TrimLeft(s, " \t\n")
+	return s != "" && strings.IndexByte("([:", s[0]) >= 0
+}
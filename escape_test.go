@@ -0,0 +1,112 @@
+package godoc2md
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
+	"github.com/mmarkdown/mmark/mparser"
+)
+
+// commentText is random comment text, biased towards characters that have a meaning in markdown.
+type commentText string
+
+func (commentText) Generate(r *rand.Rand, size int) reflect.Value {
+	pieces := []string{
+		"a", "b", "Z", "9", "1.", "2)", "foo", "Bar", " ", " ", " ", "\n",
+		"\\", "`", "*", "_", "[", "]", "(", ")", "<", ">", "<T>", "</b>", "|", "~", "^", "{", "}", "#", "-",
+		"+", "=", ":", "!", "&", "&amp;", "&#42;", "(!", "(#", "(@", "A>", "{#id}", "{{x}}", "$", "%", ".",
+		"[Name]", "[pkg.Name]", ".#",
+	}
+	var b strings.Builder
+	for i := 0; i < size; i++ {
+		b.WriteString(pieces[r.Intn(len(pieces))])
+	}
+	// comments as given to toMd have no blank lines in a paragraph and no leading or trailing white space
+	var lines []string
+	for _, l := range strings.Split(b.String(), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return reflect.ValueOf(commentText(strings.Join(lines, "\n")))
+}
+
+// plainText returns the text of the rendered document.
+func plainText(doc ast.Node) string {
+	var b strings.Builder
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch n := node.(type) {
+		case *ast.Text:
+			b.Write(n.Literal)
+		case *ast.Softbreak, *ast.Hardbreak:
+			b.WriteString("\n")
+		case *ast.Paragraph:
+			b.WriteString("\n")
+		}
+		return ast.GoToNext
+	})
+	return b.String()
+}
+
+func fields(s string) string { return strings.Join(strings.Fields(s), " ") }
+
+func TestEscapeRoundTrip(t *testing.T) {
+	contexts := map[string]func(string) string{
+		"paragraph": func(s string) string { return escapeMd(s, ctxPara) },
+		"heading":   func(s string) string { return "### " + escapeMd(strings.Replace(s, "\n", " ", -1), ctxHeading) },
+		"table":     func(s string) string { return "| x |\n|---|\n| " + escapeMd(s, ctxTable) + " |" },
+		"link": func(s string) string {
+			return "[" + escapeMd(strings.Replace(s, "\n", " ", -1), ctxLink) + "](https://example.org)"
+		},
+	}
+	for name, ext := range map[string]parser.Extensions{"commonmark": parser.CommonExtensions, "mmark": mparser.Extensions} {
+		for ctx, escape := range contexts {
+			f := func(text commentText) bool {
+				escaped := escape(string(text))
+				doc := markdown.Parse([]byte(escaped+"\n"), parser.NewWithExtensions(ext))
+				got := plainText(doc)
+				if ctx == "table" {
+					got = strings.TrimPrefix(strings.TrimSpace(got), "x")
+				}
+				if fields(got) != fields(string(text)) {
+					t.Logf("%s, %s: %q escaped as %q, rendered as %q", name, ctx, text, escaped, got)
+					return false
+				}
+				return true
+			}
+			if err := quick.Check(f, &quick.Config{MaxCount: 2000}); err != nil {
+				t.Errorf("%s, %s: %s", name, ctx, err)
+			}
+		}
+	}
+}
+
+func TestEscapeMd(t *testing.T) {
+	tests := []struct {
+		in  string
+		ctx mdContext
+		exp string
+	}{
+		{"Returns <T> for a_b_c.", ctxPara, `Returns \<T> for a\_b\_c.`},
+		{"# not a heading\n1. not a list", ctxPara, "\\# not a heading\n1\\. not a list"},
+		{"See [Name] and [pkg.Name].", ctxPara, "See [Name] and [pkg.Name]."},
+		{"Not a doc link: [Name](url)", ctxPara, `Not a doc link: \[Name\](url)`},
+		{"Heading #", ctxHeading, `Heading \#`},
+		{"a|b\nc", ctxTable, `a\|b c`},
+		{"func (*T) [M]", ctxLink, `func (\*T) \[M\]`},
+	}
+	for _, tc := range tests {
+		if got := escapeMd(tc.in, tc.ctx); got != tc.exp {
+			t.Errorf("escapeMd(%q): expected %q, got %q", tc.in, tc.exp, got)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package godoc2md
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/doc"
+	"go/format"
+	"go/printer"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/godoc"
+)
+
+// exampleOutputRx matches the output comment that ends an example.
+var exampleOutputRx = regexp.MustCompile(`(?i)//[[:space:]]*(unordered )?output:`)
+
+// splitExample returns the name of the symbol the example name is for and its suffix: "T_M_foo"
+// returns "T_M" and "foo".
+func splitExample(name string) (string, string) {
+	if i := strings.LastIndex(name, "_"); i >= 0 && i < len(name)-1 {
+		if r, _ := utf8.DecodeRuneInString(name[i+1:]); !unicode.IsUpper(r) {
+			return name[:i], name[i+1:]
+		}
+	}
+	return name, ""
+}
+
+// exampleMd returns the markdown of the examples of the symbol name, "" for the package, "F", "T" or
+// "T_M": a heading, the doc, the code without the output comment and the expected output.
+func exampleMd(info *godoc.PageInfo, name string, config *Config) (string, error) {
+	if info == nil {
+		return "", nil
+	}
+	links := newDocLinks(info)
+	b := &strings.Builder{}
+	for _, eg := range info.Examples {
+		sym, suffix := splitExample(eg.Name)
+		if sym != name {
+			continue
+		}
+		title := "Example"
+		if suffix != "" {
+			title += " (" + strings.ToUpper(suffix[:1]) + suffix[1:] + ")"
+		}
+		fmt.Fprintf(b, "\n#### %s {#example_%s}\n\n", escapeMd(title, ctxHeading), eg.Name)
+		toMd(b, eg.Doc, config, links)
+
+		code, err := exampleCode(info, eg)
+		if err != nil {
+			return "", err
+		}
+		lines := strings.Split(code, "\n")
+		fence := codeFence(lines)
+		fmt.Fprintf(b, "\nCode:\n\n%s go\n%s\n%s\n", fence, code, fence)
+		if eg.Output == "" {
+			continue
+		}
+		out := strings.Split(strings.TrimSuffix(eg.Output, "\n"), "\n")
+		fence = codeFence(out)
+		if eg.Unordered {
+			b.WriteString("\nUnordered output:\n\n")
+		} else {
+			b.WriteString("\nOutput:\n\n")
+		}
+		fmt.Fprintf(b, "%s text\n%s\n%s\n", fence, strings.Join(out, "\n"), fence)
+	}
+	return b.String(), nil
+}
+
+// exampleCode returns the code of the example: the body of the example function, unindented and
+// without the output comment. A whole file example is returned as is.
+func exampleCode(info *godoc.PageInfo, eg *doc.Example) (string, error) {
+	buf := &bytes.Buffer{}
+	cnode := &printer.CommentedNode{Node: eg.Code, Comments: eg.Comments}
+	if err := (&printer.Config{Mode: printer.UseSpaces, Tabwidth: 4}).Fprint(buf, info.FSet, cnode); err != nil {
+		return "", err
+	}
+	code := buf.String()
+	if n := len(code); n < 2 || code[0] != '{' || code[n-1] != '}' {
+		return code, nil
+	}
+	code = code[1 : len(code)-1]
+	if loc := exampleOutputRx.FindStringIndex(code); loc != nil {
+		code = code[:loc[0]]
+	}
+	lines := strings.Split(strings.Trim(code, "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimPrefix(lines[i], "    ")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
+
+// ExampleResult is the outcome of verifying an example, see VerifyExamples.
+type ExampleResult struct {
+	Name    string // Name of the example function, e.g. ExampleT_M.
+	Pos     string // Position of the example in the source, file:line.
+	Status  string // "ok", "fail" (wrong output) or "error" (doesn't build, panics, times out).
+	Message string // Why the example failed.
+	Got     string // Output of the example, if it ran.
+	Want    string // Output in the example's output comment.
+}
+
+// Failed returns true if the example didn't build, didn't run or its output is wrong.
+func (r ExampleResult) Failed() bool { return r.Status == "fail" || r.Status == "error" }
+
+// ExamplesError is returned by Transform when Config.VerifyExamples is set and examples fail. The
+// documentation is still written.
+type ExamplesError struct {
+	Path    string
+	Results []ExampleResult // Only the failed examples.
+}
+
+func (e *ExamplesError) Error() string {
+	names := make([]string, len(e.Results))
+	for i, r := range e.Results {
+		names[i] = r.Name
+	}
+	return fmt.Sprintf("%s: %d failing examples: %s", e.Path, len(e.Results), strings.Join(names, ", "))
+}
+
+// VerifyExamples builds and runs the examples that are rendered for the package in path, like go test
+// does, and compares their output with their output comment. Each example is built in a temporary
+// module that replaces the module of the package with its directory, so the examples are compiled
+// against the code on disk. Examples that can't be built on their own, those in the package itself or
+// that use its unexported identifiers, are run in place with go test instead. Examples without an
+// output comment are built but not run. Each example runs for at most Config.ExampleTimeout.
+func VerifyExamples(path string, config *Config) ([]ExampleResult, error) {
+	fs, pres := presentation(config)
+	info, err := load(fs, pres, path, config)
+	if err != nil {
+		return nil, err
+	}
+	if len(info.Examples) == 0 {
+		return nil, nil
+	}
+	root, mod := moduleRoot(path)
+	if root == "" {
+		return nil, fmt.Errorf("%s: no go.mod, can't build examples", path)
+	}
+	tmp, err := os.MkdirTemp("", "godoc2md-examples")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	gomod := fmt.Sprintf("module godoc2md.example\n\nrequire %s v0.0.0-00010101000000-000000000000\n\nreplace %s => %s\n", mod, mod, root)
+	if err := os.WriteFile(filepath.Join(tmp, "go.mod"), []byte(gomod), 0644); err != nil {
+		return nil, err
+	}
+	if sum, err := os.ReadFile(filepath.Join(root, "go.sum")); err == nil {
+		if err := os.WriteFile(filepath.Join(tmp, "go.sum"), sum, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	timeout := config.ExampleTimeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	var results []ExampleResult
+	for _, eg := range info.Examples {
+		p := info.FSet.Position(eg.Code.Pos())
+		r := ExampleResult{Name: "Example" + eg.Name, Pos: fmt.Sprintf("%s:%d", p.Filename, p.Line), Want: eg.Output}
+		if eg.Play == nil {
+			testExample(&r, eg, path, timeout)
+			results = append(results, r)
+			continue
+		}
+		verifyExample(&r, eg, info, tmp, timeout)
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// verifyExample builds the example eg in the module in dir and runs it, the result is recorded in r.
+func verifyExample(r *ExampleResult, eg *doc.Example, info *godoc.PageInfo, dir string, timeout time.Duration) {
+	src := &bytes.Buffer{}
+	if err := format.Node(src, info.FSet, eg.Play); err != nil {
+		r.Status, r.Message = "error", err.Error()
+		return
+	}
+	main := filepath.Join(dir, r.Name)
+	if err := os.MkdirAll(main, 0755); err != nil {
+		r.Status, r.Message = "error", err.Error()
+		return
+	}
+	if err := os.WriteFile(filepath.Join(main, "main.go"), src.Bytes(), 0644); err != nil {
+		r.Status, r.Message = "error", err.Error()
+		return
+	}
+
+	bin := filepath.Join(main, "example")
+	build := exec.Command("go", "build", "-o", bin, ".")
+	build.Dir = main
+	build.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	if out, err := build.CombinedOutput(); err != nil {
+		r.Status, r.Message = "error", "build failed: "+strings.TrimSpace(string(out))
+		return
+	}
+	if eg.Output == "" && !eg.EmptyOutput {
+		r.Status = "ok" // compiled, but not run
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	run := exec.CommandContext(ctx, bin)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	run.Stdout, run.Stderr = stdout, stderr
+	err := run.Run()
+	r.Got = stdout.String()
+	switch {
+	case ctx.Err() != nil:
+		r.Status, r.Message = "error", fmt.Sprintf("timed out after %s", timeout)
+		return
+	case err != nil:
+		r.Status, r.Message = "error", fmt.Sprintf("%s: %s", err, strings.TrimSpace(stderr.String()))
+		return
+	}
+
+	got, want := strings.TrimSpace(r.Got), strings.TrimSpace(eg.Output)
+	if eg.Unordered {
+		got, want = sortLines(got), sortLines(want)
+	}
+	r.Status = "ok"
+	if got != want {
+		r.Status, r.Message = "fail", "output differs"
+	}
+}
+
+// sortLines sorts the lines of s, as go test does for unordered output.
+func sortLines(s string) string {
+	lines := strings.Split(s, "\n")
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// testExample runs the example eg with go test in the package directory dir, the result is recorded in
+// r. It is used for the examples verifyExample can't build.
+func testExample(r *ExampleResult, eg *doc.Example, dir string, timeout time.Duration) {
+	cmd := exec.Command("go", "test", "-count=1", "-v", "-timeout", timeout.String(), "-run", "^"+regexp.QuoteMeta(r.Name)+"$", ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	output := string(out)
+	switch {
+	case strings.Contains(output, "--- PASS: "+r.Name+" "):
+		r.Status = "ok"
+	case err == nil && eg.Output == "" && !eg.EmptyOutput:
+		r.Status = "ok" // compiled, but not run
+	case strings.Contains(output, "panic: test timed out"):
+		r.Status, r.Message = "error", fmt.Sprintf("timed out after %s", timeout)
+	case strings.Contains(output, "--- FAIL: "+r.Name+" ") && strings.Contains(output, "\ngot:\n"):
+		r.Status, r.Message = "fail", "output differs"
+		got := output[strings.Index(output, "\ngot:\n")+len("\ngot:\n"):]
+		if i := strings.Index(got, "\nwant"); i >= 0 {
+			got = got[:i+1]
+		}
+		r.Got = got
+	default:
+		r.Status, r.Message = "error", "go test failed: "+strings.TrimSpace(output)
+	}
+}
+
+// moduleRoot returns the directory of the go.mod that dir is in and the module path from it.
+func moduleRoot(dir string) (string, string) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", ""
+	}
+	for {
+		if mod := modulePath(dir); mod != "" {
+			return dir, mod
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,114 @@
+package godoc2md
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestVerifyExamples(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not found")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/p\n\ngo 1.17\n",
+		"p.go": `// Package p greets.
+package p
+
+// Hello returns a greeting.
+func Hello() string { return greeting }
+
+const greeting = "hello"
+`,
+		"p_test.go": `package p_test
+
+import (
+	"fmt"
+
+	"example.com/p"
+)
+
+// Print a greeting.
+func ExampleHello() {
+	fmt.Println(p.Hello())
+	// Output: hello
+}
+
+func ExampleHello_wrong() {
+	fmt.Println(p.Hello())
+	// Output: goodbye
+}
+
+func ExampleHello_unordered() {
+	fmt.Println("b")
+	fmt.Println(p.Hello())
+	// Unordered output:
+	// hello
+	// b
+}
+
+func ExampleHello_indented() {
+	fmt.Println(p.Hello())
+	fmt.Println(" b")
+	// Unordered output:
+	// b
+	// hello
+}
+`,
+		"p_internal_test.go": `package p
+
+import "fmt"
+
+func ExampleHello_internal() {
+	fmt.Println(greeting)
+	// Output: hello
+}
+
+func ExampleHello_internalWrong() {
+	fmt.Println(greeting)
+	// Output: goodbye
+}
+`,
+	}
+	writeFiles(t, dir, files)
+	config := &Config{Import: "example.com/p"}
+	results, err := VerifyExamples(dir, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := map[string]string{}
+	for _, r := range results {
+		status[r.Name] = r.Status
+	}
+	want := map[string]string{
+		"ExampleHello": "ok", "ExampleHello_wrong": "fail", "ExampleHello_unordered": "ok", "ExampleHello_indented": "fail",
+		"ExampleHello_internal": "ok", "ExampleHello_internalWrong": "fail",
+	}
+	if diff := cmp.Diff(want, status); diff != "" {
+		t.Errorf("unexpected results (-want +got):\n%s", diff)
+	}
+	for _, r := range results {
+		if r.Name == "ExampleHello_internalWrong" && r.Got != "hello\n" {
+			t.Errorf("expected output %q of %s, got %q", "hello\n", r.Name, r.Got)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	config.VerifyExamples = true
+	err = Transform(buf, dir, config)
+	if e, ok := err.(*ExamplesError); !ok || len(e.Results) != 3 {
+		t.Errorf("expected 3 failing examples, got %v", err)
+	}
+	for _, s := range []string{
+		"#### Example {#example_Hello}\n\nPrint a greeting.\n\nCode:\n\n``` go\nfmt.Println(p.Hello())\n```\n\nOutput:\n\n``` text\nhello\n```\n",
+		"#### Example (Unordered) {#example_Hello_unordered}\n",
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q in output, got:\n%s", s, buf.String())
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package godoc2md
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	pathpkg "path"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// nsContext returns build.Default with its file system operations done in ns, like godoc does.
+func nsContext(ns vfs.NameSpace) build.Context {
+	ctxt := defaultContext()
+	ctxt.IsAbsPath = pathpkg.IsAbs
+	ctxt.IsDir = func(p string) bool {
+		fi, err := ns.Stat(p)
+		return err == nil && fi.IsDir()
+	}
+	ctxt.ReadDir = ns.ReadDir
+	ctxt.OpenFile = func(p string) (io.ReadCloser, error) { return ns.Open(p) }
+	return ctxt
+}
+
+// parseDir parses the Go files of the package in the directory dir of ns.
+func parseDir(ns vfs.NameSpace, dir string, fset *token.FileSet, mode parser.Mode) (*build.Package, []*ast.File, error) {
+	ctxt := nsContext(ns)
+	bpkg, err := ctxt.ImportDir(dir, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var files []*ast.File
+	for _, name := range bpkg.GoFiles {
+		p := pathpkg.Join(dir, name)
+		src, err := vfs.ReadFile(ns, p)
+		if err != nil {
+			return nil, nil, err
+		}
+		f, err := parser.ParseFile(fset, p, src, mode)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, f)
+	}
+	return bpkg, files, nil
+}
+
+// osNameSpace returns a name space with the directory dir on disk bound at dir.
+func osNameSpace(dir string) vfs.NameSpace {
+	ns := vfs.NameSpace{}
+	ns.Bind(dir, osFS(dir), "/", vfs.BindReplace)
+	return ns
+}
+
+// TransformFS is like Transform, but reads the package from the directory dir in fsys, e.g. an
+// embed.FS, a fstest.MapFS, a *zip.Reader holding a module zip as served by a module proxy, or a tar
+// archive read with TarFS. Nothing is read from disk. The files are named as with Transform for the
+// relative path dir, "dir/name.go", which Config.Replace and Config.SubPackage apply to as usual; for
+// a module zip, whose files are in "module@version/", Replace is typically "module@version". Since,
+// Matrix and VerifyExamples need the package on disk and can't be used.
+func TransformFS(out io.Writer, fsys fs.FS, dir string, config *Config) error {
+	if config.Since || len(config.Matrix) > 0 || config.VerifyExamples {
+		return errors.New("Since, Matrix and VerifyExamples can't be used with a fs.FS")
+	}
+	if _, _, err := config.prepare(); err != nil {
+		return err
+	}
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	ns, pres := presentation(config)
+	info, err := loadFrom(ns, pres, pathpkg.Clean(dir), vfs.FromFS(sub), config)
+	if err != nil {
+		return err
+	}
+	return writePackage(out, ns, pres, info, config)
+}
+
+// TarFS reads the tar archive r into memory and returns it as a file system, for use with TransformFS.
+// Only regular files and directories are kept, a compressed archive must be decompressed by the
+// caller.
+func TarFS(r io.Reader) (fs.FS, error) {
+	fsys := memFS{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fsys, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := pathpkg.Clean(hdr.Name)
+		if !fs.ValidPath(name) || name == "." {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			fsys[name] = &memFile{name: name, mode: fs.ModeDir | fs.FileMode(hdr.Mode).Perm(), modTime: hdr.ModTime}
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			fsys[name] = &memFile{name: name, data: data, mode: fs.FileMode(hdr.Mode).Perm(), modTime: hdr.ModTime}
+		}
+	}
+}
+
+// memFS is a read-only file system held in memory, by the clean path of each file. Directories that
+// only appear in the paths of files exist too.
+type memFS map[string]*memFile
+
+// memFile is a file or directory of a memFS, it is its own fs.FileInfo.
+type memFile struct {
+	name    string
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (f *memFile) Name() string       { return pathpkg.Base(f.name) }
+func (f *memFile) Size() int64        { return int64(len(f.data)) }
+func (f *memFile) Mode() fs.FileMode  { return f.mode }
+func (f *memFile) ModTime() time.Time { return f.modTime }
+func (f *memFile) IsDir() bool        { return f.mode.IsDir() }
+func (f *memFile) Sys() interface{}   { return nil }
+
+func (m memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if f, ok := m[name]; ok && !f.IsDir() {
+		return &memReader{f, bytes.NewReader(f.data)}, nil
+	}
+
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	dir := m[name]
+	children := map[string]*memFile{}
+	for p, f := range m {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		child := p[len(prefix):]
+		if i := strings.IndexByte(child, '/'); i >= 0 { // a directory on the way to f
+			child = child[:i]
+			if _, ok := children[child]; !ok {
+				children[child] = &memFile{name: prefix + child, mode: fs.ModeDir | 0555}
+			}
+			continue
+		}
+		children[child] = f
+	}
+	if dir == nil {
+		if len(children) == 0 && name != "." {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		dir = &memFile{name: name, mode: fs.ModeDir | 0555}
+	}
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, f := range children {
+		entries = append(entries, fs.FileInfoToDirEntry(f))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return &memDir{memFile: dir, entries: entries}, nil
+}
+
+// memReader is an open file of a memFS.
+type memReader struct {
+	*memFile
+	*bytes.Reader
+}
+
+func (r *memReader) Stat() (fs.FileInfo, error) { return r.memFile, nil }
+func (r *memReader) Close() error               { return nil }
+
+// memDir is an open directory of a memFS.
+type memDir struct {
+	*memFile
+	entries []fs.DirEntry
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.memFile, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+// ReadDir returns the next n entries of the directory, or all of them if n <= 0.
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
@@ -0,0 +1,78 @@
+package godoc2md
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTransformFS(t *testing.T) {
+	exp, err := os.ReadFile("testdata/testdata.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.ReadFile("testdata/testdata.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zbuf := &bytes.Buffer{}
+	zw := zip.NewWriter(zbuf)
+	w, _ := zw.Create("testdata/testdata.go")
+	w.Write(src)
+	zw.Close()
+	zr, err := zip.NewReader(bytes.NewReader(zbuf.Bytes()), int64(zbuf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tbuf := &bytes.Buffer{}
+	tw := tar.NewWriter(tbuf)
+	tw.WriteHeader(&tar.Header{Name: "testdata/", Typeflag: tar.TypeDir, Mode: 0755})
+	tw.WriteHeader(&tar.Header{Name: "testdata/testdata.go", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(src))})
+	tw.Write(src)
+	tw.Close()
+	tr, err := TarFS(tbuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(tr, "testdata/testdata.go"); err != nil {
+		t.Error(err)
+	}
+	implicit := memFS{"a/b/c.go": {name: "a/b/c.go", data: src}} // directories without an entry
+	if err := fstest.TestFS(implicit, "a/b/c.go"); err != nil {
+		t.Error(err)
+	}
+
+	for name, fsys := range map[string]fs.FS{
+		"dir": os.DirFS("."),
+		"map": fstest.MapFS{"testdata/testdata.go": {Data: src}},
+		"zip": zr,
+		"tar": tr,
+	} {
+		config := &Config{Import: "testdata", SrcLinkHashFormat: "#L%d"}
+		buf := &bytes.Buffer{}
+		if err := TransformFS(buf, fsys, "testdata", config); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if diff := cmp.Diff(string(exp), buf.String()); diff != "" {
+			t.Errorf("%s: unexpected diff: %s", name, diff)
+		}
+	}
+
+	cmd := fstest.MapFS{"cmd/x/main.go": {Data: []byte("// X does things.\npackage main\n\nimport \"flag\"\n\nvar v = flag.Bool(\"v\", false, \"be verbose\")\n\nfunc main() { flag.Parse() }\n")}}
+	buf := &bytes.Buffer{}
+	if err := TransformFS(buf, cmd, "cmd/x", &Config{Import: "example.com/cmd/x"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "| `-v` | bool | `false` | be verbose |") {
+		t.Errorf("expected usage of command, got:\n%s", buf.String())
+	}
+}
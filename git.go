@@ -0,0 +1,95 @@
+package godoc2md
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitRoot returns the top level directory of the git repository dir is in, and the path of dir
+// relative to it.
+func gitRoot(dir string) (root, rel string, err error) {
+	top, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return "", "", err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	root, _ = filepath.EvalSymlinks(strings.TrimSpace(string(top)))
+	if abs, err = filepath.EvalSymlinks(abs); err != nil {
+		return "", "", err
+	}
+	rel, err = filepath.Rel(root, abs)
+	return root, rel, err
+}
+
+// gitHasPath returns true if rel, a path relative to the root of the repository, exists at the commit.
+func gitHasPath(root, commit, rel string) (bool, error) {
+	out, err := exec.Command("git", "-C", root, "ls-tree", "--name-only", commit, "--", filepath.ToSlash(rel)).Output()
+	if err != nil {
+		return false, err
+	}
+	return len(bytes.TrimSpace(out)) > 0, nil
+}
+
+// Checkout extracts the directory dir, as it is at the git ref, in a temporary directory and returns
+// the path of dir in there. The returned function removes the temporary directory.
+func Checkout(ref, dir string) (string, func(), error) {
+	root, rel, err := gitRoot(dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	git := exec.Command("git", "-C", root, "archive", "--format=tar", ref, "--", filepath.ToSlash(rel))
+	stderr := &bytes.Buffer{}
+	git.Stderr = stderr
+	tarball, err := git.Output()
+	if err != nil {
+		return "", nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	tmp, err := os.MkdirTemp("", "godoc2md")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmp) }
+	tr := tar.NewReader(bytes.NewReader(tarball))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		p := filepath.Join(tmp, filepath.FromSlash(hdr.Name))
+		if !strings.HasPrefix(p, tmp+string(filepath.Separator)) {
+			continue // path escapes the temporary directory
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		buf, err := io.ReadAll(tr)
+		if err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		if err := os.WriteFile(p, buf, 0644); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+	}
+	return filepath.Join(tmp, rel), cleanup, nil
+}
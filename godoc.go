@@ -12,9 +12,11 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"os"
 	"path"
 	"strings"
 	"text/template"
+	"time"
 
 	"golang.org/x/tools/godoc"
 	"golang.org/x/tools/godoc/vfs"
@@ -29,7 +31,7 @@ var (
 		"md":            mdFunc,
 		"pre":           preFunc,
 		"kebab":         kebabFunc,
-		"bitscape":      bitscapeFunc, // Escape [] for bitbucket confusion
+		"bitscape":      bitscapeFunc, // Escape link text, originally only [] for bitbucket confusion
 		"subdir_format": path.Base,
 	}
 )
@@ -44,20 +46,95 @@ type Config struct {
 	Replace           string
 	Import            string
 	SubPackage        string // If this is a subpackage, this hold the relative import
+	Repo              string // Import path of the repository that source links point into, defaults to Import without SubPackage.
+	PackageName       string // Package to document in a directory with several packages, see PackagesError.
 	GitRef            string // commit, tag, or branch of the repo.
+
+	Format        string // Output format, "markdown" or one of Formats, defaults to markdown.
+	Flavor        string // Markdown flavor to generate, see Flavors, defaults to "mmark".
+	Anchors       string // Anchor style, see Anchors, defaults to the one of the flavor.
+	HeadingOffset int    // Shift all generated headings this many levels down.
+	TOCDepth      int    // Depth of the Index and the table of contents of comments, 0 is unlimited, -1 disables them.
+	TOCDetails    bool   // Render tables of contents in a collapsible <details> block, if the flavor allows it.
+
+	// Only write a quick reference: the Index, with the synopsis of each symbol, its anchor and links to
+	// the source, and the constants and variables, without the comments and declarations.
+	QuickRef       bool
+	QuickRefBudget int // Size in bytes the quick reference should fit in by truncating synopses, 0 is unlimited.
+
+	URLSchemes []string // Extra URL schemes, besides http, https, ftp, etc., that are turned into links, e.g. "ssh".
+	NoLinkify  bool     // Don't turn URLs in comments into links.
+
+	CodeLang    string // Language of code blocks in comments that aren't recognized, defaults to "text".
+	NoGuessLang bool   // Don't guess the language of code blocks in comments, always use CodeLang.
+
+	Graph        *GraphOptions // Include the import graph in the module index, see ModuleIndex.
+	ClassDiagram bool          // Include a Mermaid class diagram of the package's types in the Overview.
+
+	Since      bool   // Show the release in which functions, types and methods were added, see Since.
+	SinceCache string // Directory of the cache used by Since, defaults to godoc2md/since in the user's cache directory.
+
+	VerifyExamples bool          // Build and run the examples after generating the documentation, see VerifyExamples.
+	ExampleTimeout time.Duration // Maximum time an example may run for, defaults to 10 seconds.
+
+	// Platform and build tags that select the files of a package, defaults to build.Default's. Godoc only
+	// reads build.Default, so it is changed while the package is loaded: calls in this package are
+	// serialized and see the right context, but other goroutines reading build.Default may see the change.
+	BuildContext *BuildContext
+	// Document all files built in at least one of these contexts, and annotate functions, types and
+	// methods that aren't available in all of them with the contexts they are built in. This changes
+	// build.Default like BuildContext.
+	Matrix []BuildContext
+}
+
+// Flavor describes the capabilities of a markdown flavor.
+type Flavor struct {
+	Details bool   // HTML <details> blocks are rendered.
+	Anchors string // Default anchor style, see Anchors.
+}
+
+// Flavors holds all known markdown flavors, callers may add their own.
+var Flavors = map[string]*Flavor{
+	"mmark":     {Anchors: "godoc"},
+	"github":    {Details: true, Anchors: "github"},
+	"gitlab":    {Details: true, Anchors: "gitlab"},
+	"bitbucket": {Anchors: "bitbucket"},
+}
+
+// flavor returns the markdown flavor configured in c.
+func (c *Config) flavor() (*Flavor, error) {
+	name := c.Flavor
+	if name == "" {
+		name = "mmark"
+	}
+	f, ok := Flavors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown markdown flavor: %q", name)
+	}
+	return f, nil
+}
+
+// toc returns true if a table of contents of the given depth should be generated.
+func (c *Config) toc(depth int) bool { return c.TOCDepth == 0 || c.TOCDepth >= depth }
+
+// details returns the opening and closing HTML for a collapsible table of contents with summary. If
+// this isn't enabled or allowed, both are empty.
+func (c *Config) details(summary string) (string, string) {
+	f, err := c.flavor()
+	if err != nil || !c.TOCDetails || !f.Details {
+		return "", ""
+	}
+	return "\n<details><summary>" + template.HTMLEscapeString(summary) + "</summary>\n\n", "\n</details>\n"
 }
 
 func commentMdFunc(comment string) string {
 	var buf bytes.Buffer
-	toMd(&buf, comment)
+	toMd(&buf, comment, &Config{}, nil)
 	return buf.String()
 }
 
-func mdFunc(text string) string {
-	text = strings.Replace(text, "*", "\\*", -1)
-	text = strings.Replace(text, "_", "\\_", -1)
-	return text
-}
+// mdFunc escapes text for use in a heading.
+func mdFunc(text string) string { return escapeMd(text, ctxHeading) }
 
 func preFunc(text string) string {
 	return "``` go\n" + text + "\n```"
@@ -88,39 +165,88 @@ func genSrcPosLinkFunc(srcLinkFormat, srcLinkHashFormat string, config *Config)
 		}
 		b := buf.String()
 		if strings.HasPrefix(b, config.Replace) {
-			url := urlForFile(b[len(config.Replace):], config.Import, config.GitRef, config.SubPackage)
-			return url
+			return config.fileURL(b[len(config.Replace):])
 		}
 		return b
 	}
 }
 
-func readTemplate(pres *godoc.Presentation, name, data string) (*template.Template, error) {
-	t, err := template.New(name).Funcs(pres.FuncMap()).Funcs(Funcs).Parse(data)
+func readTemplate(pres *godoc.Presentation, ns vfs.NameSpace, name, data string, config *Config, info *godoc.PageInfo) (*template.Template, error) {
+	links := newDocLinks(info)
+	var since map[string]string
+	var platforms map[string][]string
+	configFuncs := map[string]interface{}{
+		"comment_md": func(comment string) string {
+			var buf bytes.Buffer
+			toMd(&buf, comment, config, links)
+			return buf.String()
+		},
+		"toc": config.toc,
+		"index_url": func(info *godoc.PageInfo, decl interface{}, id string) string {
+			return "#" + id
+		},
+		"synopsis_md":  func(doc string) string { return "" },
+		"index_anchor": func(id, heading string) string { return "" },
+		"since": func(id string) (string, error) {
+			if !config.Since || info == nil {
+				return "", nil
+			}
+			if since == nil {
+				var err error
+				if since, err = Since(info.Dirname, config); err != nil {
+					return "", err
+				}
+			}
+			if v := since[id]; v != "" {
+				return " *added in " + v + "*", nil
+			}
+			return "", nil
+		},
+		"platforms": func(id string) string {
+			if len(config.Matrix) == 0 || info == nil {
+				return ""
+			}
+			if platforms == nil {
+				platforms = availability(info.Dirname, config)
+			}
+			return platformsMd(platforms[id])
+		},
+		"class_diagram": func() (string, error) {
+			if !config.ClassDiagram {
+				return "", nil
+			}
+			return classDiagram(ns, info)
+		},
+		"example_md": func(name string) (string, error) {
+			return exampleMd(info, name, config)
+		},
+		"usage": func() (string, error) {
+			if info == nil || !info.IsMain {
+				return "", nil
+			}
+			return usageMd(ns, info.Dirname)
+		},
+		"details_begin": func(summary string) string {
+			begin, _ := config.details(summary)
+			return begin
+		},
+		"details_end": func() string {
+			_, end := config.details("")
+			return end
+		},
+	}
+	t, err := template.New(name).Funcs(pres.FuncMap()).Funcs(Funcs).Funcs(configFuncs).Parse(data)
 	return t, err
 }
 
-func kebabFunc(text string) string {
-	s := strings.Replace(strings.ToLower(text), " ", "-", -1)
-	s = strings.Replace(s, ".", "-", -1)
-	s = strings.Replace(s, "\\*", "42", -1)
-	return s
-}
+// kebabFunc returns the GitHub anchor for the heading text.
+func kebabFunc(text string) string { return githubSlug(headingText(text)) }
 
-func bitscapeFunc(text string) string {
-	s := strings.Replace(text, "[", "\\[", -1)
-	s = strings.Replace(s, "]", "\\]", -1)
-	return s
-}
-
-// Transform turns your godoc into markdown.The imp (import) path will be used
-// for the generated import statement, the same string is also used for generating
-// file 'files' links, but then it will be prefixed with 'https://'.
-func Transform(out io.Writer, path string, config *Config) error {
-	if config.GitRef == "" {
-		config.GitRef = "master" // main??
-	}
+// bitscapeFunc escapes text for use as the text of a link.
+func bitscapeFunc(text string) string { return escapeMd(text, ctxLink) }
 
+// presentation returns the name space and the godoc presentation configured by config.
+func presentation(config *Config) (vfs.NameSpace, *godoc.Presentation) {
 	fs := vfs.NameSpace{}
 	corpus := godoc.NewCorpus(fs)
 	corpus.Verbose = config.Verbose
@@ -131,15 +257,88 @@ func Transform(out io.Writer, path string, config *Config) error {
 	pres.DeclLinks = config.DeclLinks
 	pres.URLForSrcPos = genSrcPosLinkFunc(config.SrcLinkFormat, config.SrcLinkHashFormat, config)
 	pres.URLForSrc = func(s string) string {
-		return urlForFile(s, config.Import, config.GitRef, config.SubPackage)
+		return config.fileURL(s)
 	}
+	return fs, pres
+}
 
-	tmpl, err := readTemplate(pres, "package.txt", pkgTemplate)
+// prepare fills in the defaults of c and checks its anchor style and format, which it returns.
+func (c *Config) prepare() (*Anchor, Renderer, error) {
+	if c.GitRef == "" {
+		c.GitRef = "master" // main??
+	}
+	a, err := c.anchor()
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := c.renderer()
 	if err != nil {
+		return nil, nil, err
+	}
+	return a, r, nil
+}
+
+// Transform turns your godoc into markdown.The imp (import) path will be used
+// for the generated import statement, the same string is also used for generating
+// file 'files' links, but then it will be prefixed with 'https://'.
+func Transform(out io.Writer, path string, config *Config) error {
+	if _, _, err := config.prepare(); err != nil {
 		return err
 	}
 
-	return write(out, fs, pres, tmpl, path, config.Import)
+	fs, pres := presentation(config)
+	info, err := load(fs, pres, path, config)
+	if err != nil {
+		return err
+	}
+	if err := writePackage(out, fs, pres, info, config); err != nil {
+		return err
+	}
+	if !config.VerifyExamples {
+		return nil
+	}
+	results, err := VerifyExamples(path, config)
+	if err != nil {
+		return err
+	}
+	failed := &ExamplesError{Path: path}
+	for _, r := range results {
+		if r.Failed() {
+			failed.Results = append(failed.Results, r)
+		}
+	}
+	if len(failed.Results) > 0 {
+		return failed
+	}
+	return nil
+}
+
+// Check generates the documentation for the package in path and compares it with the contents of
+// file. If they differ a unified diff is returned, the empty string means file is up to date. A
+// non-existent file is treated as being empty. If there is nothing to document in path, file is
+// not looked at. If file contains godoc2md markers, see Inject, only the content between them is
+// checked. Failing examples, with Config.VerifyExamples, are returned as an *ExamplesError together
+// with the diff.
+func Check(file, path string, config *Config) (string, error) {
+	buf := &bytes.Buffer{}
+	examples := Transform(buf, path, config)
+	if _, ok := examples.(*ExamplesError); !ok && examples != nil {
+		return "", examples
+	}
+	if buf.Len() == 0 {
+		return "", nil
+	}
+	old, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	gen := buf.Bytes()
+	if HasMarkers(old) {
+		if gen, err = Inject(old, gen); err != nil {
+			return "", fmt.Errorf("%s: %s", file, err)
+		}
+	}
+	return unifiedDiff(file, file+" (generated)", old, gen), examples
 }
 
 // urlForFile takes path, imp and git ref and sep and creates a link to a file in
@@ -159,10 +358,22 @@ func urlForFile(s, imp, ref, subpkg string) string {
 	return "https://" + imp + sep + "/blob/" + ref + path
 }
 
+// fileURL returns the URL of the file s in the repository of the package, see urlForFile. The file is
+// either relative to the repository or in the import path of the package.
+func (c *Config) fileURL(s string) string {
+	if c.Repo == "" {
+		return urlForFile(s, c.Import, c.GitRef, c.SubPackage)
+	}
+	if c.Import != "" && strings.HasPrefix(s, c.Import+"/") {
+		s = path.Join("/", c.SubPackage, s[len(c.Import):])
+	}
+	return urlForFile(s, c.Repo, c.GitRef, "")
+}
+
 // seperatorForHub returns "/-/" or the empty string, if the string s contain gitlab or not.
 func seperatorForHub(s string) string {
 	slash := strings.Index(s, "/")
-	if slash == 0 {
+	if slash <= 0 {
 		slash = len(s)
 	}
 	if strings.Contains(s[:slash], "gitlab") {
@@ -2,12 +2,30 @@ package godoc2md
 
 import (
 	"bytes"
+	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 )
 
+// writeFiles writes files, by slash separated name, to the directory dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, src := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func TestURLForFile(t *testing.T) {
 	u := urlForFile("github.com/miekg/dns/scan.go", "github.com/miekg/dns", "main", "")
 	if exp := "https://github.com/miekg/dns/blob/main/scan.go"; u != exp {
@@ -24,6 +42,15 @@ func TestURLForFile(t *testing.T) {
 	}
 }
 
+func TestSeperatorForHub(t *testing.T) {
+	// import paths without a slash, as used for local packages, must not panic
+	for s, exp := range map[string]string{"dns": "", "gitlab": "/-", "gitlab.com/dns": "/-", "/gitlab": "/-"} {
+		if sep := seperatorForHub(s); sep != exp {
+			t.Errorf("%s: expected %q, got %q", s, exp, sep)
+		}
+	}
+}
+
 func TestGoDoc(t *testing.T) {
 	config := &Config{
 		Import:            "testdata",
@@ -39,13 +66,581 @@ func TestGoDoc(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	got := bytes.TrimSpace(buf.Bytes())
-	got = append(got, '\n')
-	exp = bytes.Replace(exp, []byte("\n\n"), []byte("\n"), -1)
-	got = bytes.Replace(got, []byte("\n\n"), []byte("\n"), -1)
-	got = bytes.Replace(got, []byte(" \n"), []byte("\n"), -1) // add space newline is something inserted.
-	diff := cmp.Diff(exp, got)
+	diff := cmp.Diff(string(exp), buf.String())
 	if diff != "" {
 		t.Errorf("unexpected diff: %s", diff)
 	}
 }
+
+func TestCheck(t *testing.T) {
+	config := &Config{
+		Import:            "testdata",
+		SrcLinkHashFormat: "#L%d",
+	}
+
+	diff, err := Check("testdata/testdata.md", "testdata", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != "" {
+		t.Errorf("expected no diff, got %s", diff)
+	}
+
+	diff, err = Check("testdata/does-not-exist.md", "testdata", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(diff, "--- testdata/does-not-exist.md\n") {
+		t.Errorf("expected diff for non-existent file, got %s", diff)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := []byte("a\nb\nc\nd\ne\nf\ng\nh\n")
+	b := []byte("a\nb\nc\nD\ne\nf\ng\nh\ni\n")
+	exp := `--- a
++++ b
+@@ -1,8 +1,9 @@
+ a
+ b
+ c
+-d
++D
+ e
+ f
+ g
+ h
++i
+`
+	if got := unifiedDiff("a", "b", a, b); got != exp {
+		t.Errorf("unexpected diff: %s", cmp.Diff(exp, got))
+	}
+	if got := unifiedDiff("a", "b", a, a); got != "" {
+		t.Errorf("expected no diff, got %s", got)
+	}
+
+	exp = `--- a
++++ b
+@@ -5,4 +5,4 @@
+ e
+ f
+ g
+-h
++h
+\ No newline at end of file
+`
+	if got := unifiedDiff("a", "b", a, a[:len(a)-1]); got != exp {
+		t.Errorf("unexpected diff: %s", cmp.Diff(exp, got))
+	}
+}
+
+func TestInject(t *testing.T) {
+	doc := []byte(`# testdata
+
+## Overview {#pkg-overview}
+Overview.
+
+## Index {#pkg-index}
+* [func IsError() bool](#IsError)
+
+## func [IsError](https://testdata) {#IsError}
+` + "``` go\nfunc IsError() bool\n```" + `
+IsError always returns false.
+`)
+	readme := []byte(`# My Project
+
+Hand written intro.
+
+## API
+
+<!-- godoc2md:begin:index -->
+old index
+<!-- godoc2md:end -->
+
+### Errors
+
+<!-- godoc2md:begin:func=IsError -->
+<!-- godoc2md:end:func=IsError -->
+
+The end.
+`)
+	exp := `# My Project
+
+Hand written intro.
+
+## API
+
+<!-- godoc2md:begin:index -->
+
+### Index {#pkg-index}
+* [func IsError() bool](#IsError)
+
+<!-- godoc2md:end -->
+
+### Errors
+
+<!-- godoc2md:begin:func=IsError -->
+
+#### func [IsError](https://testdata) {#IsError}
+` + "``` go\nfunc IsError() bool\n```" + `
+IsError always returns false.
+
+<!-- godoc2md:end:func=IsError -->
+
+The end.
+`
+	got, err := Inject(readme, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(exp, string(got)); diff != "" {
+		t.Errorf("unexpected diff: %s", diff)
+	}
+	// injecting again must not change anything
+	again, err := Inject(got, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(got), string(again)); diff != "" {
+		t.Errorf("inject is not idempotent: %s", diff)
+	}
+
+	if _, err := Inject([]byte("<!-- godoc2md:begin -->\n"), doc); err == nil {
+		t.Errorf("expected error for missing end marker")
+	}
+	if _, err := Inject([]byte("<!-- godoc2md:begin:type=Foo -->\n<!-- godoc2md:end -->\n"), doc); err == nil {
+		t.Errorf("expected error for unknown section")
+	}
+}
+
+func TestFenced(t *testing.T) {
+	tests := []struct {
+		line, fence, exp string
+	}{
+		{"```", "", "```"},
+		{"```    ", "", "```"}, // trailing white space isn't indentation
+		{" ``` ", "", "```"},
+		{"~~~~ go", "", "~~~~"},
+		{"    ```", "", ""}, // indented code
+		{"```\t", "```", ""},
+		{"~~~", "````", "````"},
+	}
+	for _, tc := range tests {
+		if got := fenced(tc.line, tc.fence); got != tc.exp {
+			t.Errorf("fenced(%q, %q): expected %q, got %q", tc.line, tc.fence, tc.exp, got)
+		}
+	}
+
+	got := string(shiftHeadings([]byte("```    \n# code\n```  \n# heading\n"), 1))
+	if exp := "```    \n# code\n```  \n## heading\n"; got != exp {
+		t.Errorf("expected %q, got %q", exp, got)
+	}
+}
+
+func TestHeadingOffsetTOCDepth(t *testing.T) {
+	config := &Config{
+		Import:            "testdata",
+		SrcLinkHashFormat: "#L%d",
+		HeadingOffset:     1,
+		TOCDepth:          -1,
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Transform(buf, "testdata", config); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	if !strings.HasPrefix(got, "## testdata\n") {
+		t.Errorf("expected headings to be shifted, got %s", got)
+	}
+	if strings.Contains(got, "* [Overview](#pkg-overview)") || strings.Contains(got, "(#IsError)") {
+		t.Errorf("expected no table of contents, got %s", got)
+	}
+}
+
+func TestLinks(t *testing.T) {
+	links := &docLinks{
+		pkg:     "dns",
+		keys:    map[string]string{"Client": "Client", "Client.Exchange": "Client.Exchange", "MaxMsgSize": "pkg-constants"},
+		imports: map[string]string{"context": "context", "idna": "golang.org/x/net/idna"},
+	}
+	tests := []struct {
+		in     string
+		config *Config
+		exp    string
+	}{
+		{"See https://example.org/a_b.", &Config{}, "See <https://example.org/a_b>.\n"},
+		{"(see https://en.wikipedia.org/wiki/Go_(language))", &Config{}, "(see <https://en.wikipedia.org/wiki/Go_(language)>)\n"},
+		{"Clone ssh://git@example.org/repo, or not.", &Config{URLSchemes: []string{"ssh"}}, "Clone <ssh://git@example.org/repo>, or not.\n"},
+		{"See https://example.org/a_b.", &Config{NoLinkify: true}, "See https://example.org/a\\_b.\n"},
+		{"Use [Client] or [*Client].", &Config{}, "Use [Client](#Client) or [\\*Client](#Client).\n"},
+		{"Call [Client.Exchange] with [dns.MaxMsgSize].", &Config{}, "Call [Client.Exchange](#Client.Exchange) with [dns.MaxMsgSize](#pkg-constants).\n"},
+		{"Takes a [context.Context] and [idna.Profile].", &Config{}, "Takes a [context.Context](https://pkg.go.dev/context#Context) and [idna.Profile](https://pkg.go.dev/golang.org/x/net/idna#Profile).\n"},
+		{"See [golang.org/x/net/idna].", &Config{}, "See [golang.org/x/net/idna](https://pkg.go.dev/golang.org/x/net/idna).\n"},
+		{"Unknown [Foo] and [Client](url).", &Config{}, "Unknown [Foo] and \\[Client\\](url).\n"},
+		{"Not a link: [a/b], but [encoding/json.Decoder] is.", &Config{}, "Not a link: [a/b], but [encoding/json.Decoder](https://pkg.go.dev/encoding/json#Decoder) is.\n"},
+	}
+	for _, tc := range tests {
+		buf := &bytes.Buffer{}
+		toMd(buf, tc.in, tc.config, links)
+		if got := buf.String(); got != tc.exp {
+			t.Errorf("toMd(%q): expected %q, got %q", tc.in, tc.exp, got)
+		}
+	}
+}
+
+func TestCodeBlocks(t *testing.T) {
+	tests := []struct {
+		in  string
+		exp string
+	}{
+		{"\tx := f(1)\n\tfmt.Println(x)\n", "go"},
+		{"\tfunc F() {}\n", "go"},
+		{"\t$ go get github.com/miekg/godoc2md\n", "sh"},
+		{"\t{\"a\": [1, 2]}\n", "json"},
+		{"\tname: godoc2md\n\tdeps:\n\t  - go\n", "yaml"},
+		{"\tsome text, http://example.org\n", "text"},
+	}
+	for _, tc := range tests {
+		buf := &bytes.Buffer{}
+		toMd(buf, "Example:\n\n"+tc.in, &Config{}, nil)
+		code := strings.Replace(strings.TrimPrefix(tc.in, "\t"), "\n\t", "\n", -1)
+		if exp := "Example:\n\n\n``` " + tc.exp + "\n" + code + "```\n"; buf.String() != exp {
+			t.Errorf("toMd(%q): expected %q, got %q", tc.in, exp, buf.String())
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	toMd(buf, "Example:\n\n\tx := 1\n\t```\n", &Config{NoGuessLang: true, CodeLang: "console"}, nil)
+	if exp := "Example:\n\n\n```` console\nx := 1\n```\n````\n"; buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	cmd, err := ParseCommand("cmd/godoc2md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Usage != "usage: godoc2md [options] package" {
+		t.Errorf("expected usage text, got %q", cmd.Usage)
+	}
+	found := false
+	for _, f := range cmd.Flags {
+		if f.Name == "flavor" {
+			found = true
+			if exp := (Flag{Name: "flavor", Type: "string", Default: `"mmark"`, Usage: "markdown flavor to generate: mmark, github, gitlab or bitbucket"}); f != exp {
+				t.Errorf("expected %+v, got %+v", exp, f)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected flag -flavor, got %+v", cmd.Flags)
+	}
+}
+
+func TestParseCommandPflag(t *testing.T) {
+	dir := t.TempDir()
+	src := `package main
+
+import (
+	"fmt"
+	"os"
+
+	flag "github.com/spf13/pflag"
+)
+
+func main() {
+	fs := flag.NewFlagSet("x", flag.ExitOnError)
+	var n int
+	fs.IntVarP(&n, "count", "c", 3, "number of "+"things")
+	fs.CountP("verbose", "v", "more output")
+	fs.SetAnnotation("count", "group", []string{"a"})
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage:", "x [flags]")
+		fs.PrintDefaults()
+	}
+}
+`
+	if err := os.WriteFile(dir+"/main.go", []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := ParseCommand(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := Command{
+		Subcommands: []*Command{{
+			Name: "x",
+			Flags: []Flag{
+				{Name: "count", Shorthand: "c", Type: "int", Default: "3", Usage: "number of things", POSIX: true},
+				{Name: "verbose", Shorthand: "v", Type: "count", Usage: "more output", POSIX: true},
+			},
+			Usage: "usage: x [flags]",
+		}},
+	}
+	if diff := cmp.Diff(exp, *cmd); diff != "" {
+		t.Errorf("unexpected command (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseCommandFuncs(t *testing.T) {
+	dir := t.TempDir()
+	src := `package main
+
+import (
+	"flag"
+	"net"
+)
+
+func main() {
+	flag.BoolFunc("log", "enable logging", func(string) error { return nil })
+	flag.Func("level", "set the level", func(string) error { return nil })
+	var ip net.IP
+	flag.TextVar(&ip, "ip", net.IPv4(127, 0, 0, 1), "address to listen on")
+	flag.Set("log", "true")
+	flag.Parse()
+}
+`
+	if err := os.WriteFile(dir+"/main.go", []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := ParseCommand(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := Command{
+		Flags: []Flag{
+			{Name: "ip", Type: "value", Default: "net.IPv4(127, 0, 0, 1)", Usage: "address to listen on"},
+			{Name: "level", Type: "value", Usage: "set the level"},
+			{Name: "log", Type: "bool", Usage: "enable logging"},
+		},
+	}
+	if diff := cmp.Diff(exp, *cmd); diff != "" {
+		t.Errorf("unexpected command (-want +got):\n%s", diff)
+	}
+}
+
+func TestClassDiagram(t *testing.T) {
+	dir := t.TempDir()
+	src := `// Package shapes has shapes.
+package shapes
+
+import "io"
+
+// Shape is a shape.
+type Shape interface {
+	Area() float64
+}
+
+// Base is embedded.
+type Base struct {
+	Name string
+	hidden int
+}
+
+// Circle is round.
+type Circle struct {
+	Base
+	R float64
+	W io.Writer
+}
+
+// NewCircle returns a circle.
+func NewCircle(r float64) *Circle { return &Circle{R: r} }
+
+// Area returns the area.
+func (c *Circle) Area() float64 { return 3 * c.R * c.R }
+`
+	if err := os.WriteFile(dir+"/shapes.go", []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := Transform(buf, dir, &Config{Import: "example.org/shapes", ClassDiagram: true, Flavor: "github"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"```mermaid\nclassDiagram\n",
+		"    class Base {\n        +Name string\n    }\n",
+		"    class Circle {\n        +R float64\n        +W io.Writer\n        +NewCircle()$\n    }\n",
+		"    <<interface>> Shape\n",
+		"    Circle *-- Base\n",
+		"    Shape <|.. Circle\n",
+		"    click Circle href \"#type-circle\"\n",
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q in output, got\n%s", s, buf.String())
+		}
+	}
+}
+
+func TestAPIDiff(t *testing.T) {
+	old := `package p
+
+type T struct {
+	A int
+	B string
+}
+
+type I interface {
+	M(x int) error
+}
+
+func F(a int) {}
+
+func G(a int) {}
+
+func (T) Old() {}
+
+const C = 1
+
+func Gen[T any](x T) {}
+
+type List[T any] struct{}
+
+type Pair[K comparable, V any] struct{}
+
+type Alias = T
+
+type Named T
+`
+	cur := `package p
+
+type T struct {
+	A int
+	B []byte
+	N bool
+}
+
+type I interface {
+	M(y int) error
+	N()
+}
+
+func F(b int) {}
+
+func G(a, b int) {}
+
+func H() {}
+
+const C = 2
+
+func Gen[T comparable](x T) {}
+
+type List[T comparable] struct{}
+
+type Pair[A comparable, B any] struct{}
+
+type Alias T
+
+type Named = T
+`
+	dirs := []string{t.TempDir(), t.TempDir()}
+	for i, src := range []string{old, cur} {
+		if err := os.WriteFile(dirs[i]+"/p.go", []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changes, err := APIDiff(dirs[0], dirs[1], &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, c := range changes {
+		got = append(got, fmt.Sprintf("%s: %s %v", c.Symbol, c.Change, c.Compatible))
+	}
+	exp := []string{
+		"const C: changed false",
+		"field T.B: changed false",
+		"field T.N: added true",
+		"func G: changed false",
+		"func Gen: changed false",
+		"func H: added true",
+		"interface method I.N: added false",
+		"method T.Old: removed false",
+		"type Alias: changed false",
+		"type List: changed false",
+		"type Named: changed false",
+	}
+	if diff := cmp.Diff(exp, got); diff != "" {
+		t.Errorf("unexpected changes (-want +got):\n%s", diff)
+	}
+
+	buf := &bytes.Buffer{}
+	incompatible, err := APIReport(buf, "API changes", changes, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !incompatible {
+		t.Error("expected incompatible changes")
+	}
+	if s := "* `func G`: changed\n\n  ``` diff\n  - func G(a int)\n  + func G(a, b int)\n  ```\n"; !strings.Contains(buf.String(), s) {
+		t.Errorf("expected %q in report, got\n%s", s, buf.String())
+	}
+	if s := "  - type List[T any] struct\n  + type List[T comparable] struct\n"; !strings.Contains(buf.String(), s) {
+		t.Errorf("expected %q in report, got\n%s", s, buf.String())
+	}
+}
+
+func TestSince(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@example.org", "-C", dir}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s: %s", args, err, out)
+		}
+	}
+	release := func(tag, src string) {
+		if err := os.WriteFile(dir+"/p.go", []byte("// Package p is p.\npackage p\n\n"+src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		git("add", ".")
+		git("commit", "-q", "-m", tag)
+		git("tag", tag)
+	}
+	git("init", "-q")
+	if err := os.WriteFile(dir+"/README", []byte("p\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", ".")
+	git("commit", "-q", "-m", "v0.1.0")
+	git("tag", "v0.1.0") // before the package
+	release("v1.0.0", "func F() {}\n")
+	release("v1.1.0", "func F() {}\n\n// G is new.\nfunc G() {}\n\ntype T struct{}\n")
+	release("v1.2.0-rc.1", "func F() {}\n\nfunc G() {}\n\ntype T struct{}\n\nfunc (T) M() {}\n")
+
+	config := &Config{Import: "example.org/p", Since: true, SinceCache: t.TempDir()}
+	t.Run("checkout fails", func(t *testing.T) {
+		t.Setenv("TMPDIR", filepath.Join(dir, "missing")) // nothing must be cached
+		if _, err := Since(dir, config); err == nil {
+			t.Fatal("expected an error when tags can't be checked out")
+		}
+	})
+	for i := 0; i < 2; i++ { // second run uses the cache
+		since, err := Since(dir, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp := map[string]string{"G": "v1.1.0", "T": "v1.1.0"}; !cmp.Equal(since, exp) {
+			t.Errorf("expected %v, got %v", exp, since)
+		}
+	}
+
+	config.SrcLinkHashFormat = "#L%d"
+	buf := &bytes.Buffer{}
+	if err := Transform(buf, dir, config); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"* [func G()](#G) *added in v1.1.0*\n", "* [type T](#T) *added in v1.1.0*\n", "#L6) *added in v1.1.0* {#G}\n", "#L4) {#F}\n"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q in output, got\n%s", s, buf.String())
+		}
+	}
+	section, err := docSection(buf.Bytes(), "func=G")
+	if err != nil || !strings.Contains(string(section), "func G()") {
+		t.Errorf("expected section of G, got %q, %v", section, err)
+	}
+}
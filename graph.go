@@ -0,0 +1,329 @@
+package godoc2md
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// GraphOptions configures the import graph of a module.
+type GraphOptions struct {
+	External    bool // Include imports from outside the module, including the standard library.
+	CollapseStd bool // Collapse all standard library packages into a single "std" node.
+	NoTests     bool // Leave out the imports of _test.go files.
+	Highlight   bool // Highlight internal packages and the imports that are part of a cycle.
+}
+
+// GraphNode is a package in the import graph.
+type GraphNode struct {
+	Path     string // Import path, or "std" for the collapsed standard library.
+	Module   bool   // Package is part of the module.
+	Internal bool   // Package is an internal package.
+}
+
+// GraphEdge is an import in the import graph.
+type GraphEdge struct {
+	From, To string // Import paths of the importing and imported package.
+	Test     bool   // Only imported by _test.go files.
+	Cycle    bool   // The import is part of an import cycle, this can only happen through test imports.
+}
+
+// Graph is the import graph of a module.
+type Graph struct {
+	Module string      // Module path.
+	Nodes  []GraphNode // Nodes sorted by import path, packages of the module first.
+	Edges  []GraphEdge // Edges sorted by importing and imported package.
+}
+
+var moduleRx = regexp.MustCompile(`(?m)^module\s+"?([^"\s]+)"?\s*$`)
+
+// modulePath returns the module path from the go.mod file in dir, or the empty string.
+func modulePath(dir string) string {
+	buf, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	if m := moduleRx.FindSubmatch(buf); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// skipDir returns true if the directory name is ignored by the go tool.
+func skipDir(name string) bool {
+	return name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+// ImportGraph computes the import graph of the packages in the directory tree rooted at root. Their
+// import paths come from the modules in the tree, see FindModules, outside a module they are
+// config.Import joined with the directory relative to root. If config.Import is empty the module path
+// from root's go.mod is used. The packages of all modules in the tree are part of the graph's module.
+// The package of a directory with several packages is chosen as Transform does.
+func ImportGraph(root string, opts GraphOptions, config *Config) (*Graph, error) {
+	mods, err := FindModules(root, config.Import)
+	if err != nil {
+		return nil, err
+	}
+	imp := mods.Import
+	if imp == "" {
+		return nil, fmt.Errorf("%s: no import path and no go.mod", root)
+	}
+	prefixes := []string{imp}
+	for _, mod := range mods.Modules {
+		prefixes = append(prefixes, mod.Path)
+	}
+	inModule := func(p string) bool {
+		for _, prefix := range prefixes {
+			if p == prefix || strings.HasPrefix(p, prefix+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	g := &Graph{Module: imp}
+	nodes := map[string]*GraphNode{}
+	edges := map[[2]string]*GraphEdge{}
+	addNode := func(p string, module bool) {
+		if _, ok := nodes[p]; !ok {
+			nodes[p] = &GraphNode{Path: p, Module: module, Internal: isInternal(p)}
+		}
+	}
+	addEdge := func(from, to string, test bool) {
+		if from == to {
+			return
+		}
+		if e, ok := edges[[2]string{from, to}]; ok {
+			e.Test = e.Test && test
+			return
+		}
+		edges[[2]string{from, to}] = &GraphEdge{From: from, To: to, Test: test}
+	}
+
+	err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if p != root && skipDir(info.Name()) {
+			return filepath.SkipDir
+		}
+		ctxt, err := packageContext(p, config)
+		if _, ok := err.(*PackagesError); ok {
+			return err
+		}
+		if err != nil {
+			return nil
+		}
+		pkg, err := ctxt.ImportDir(p, 0)
+		if err != nil {
+			return nil // no Go files, or nothing buildable
+		}
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		from := mods.ImportPath(rel)
+		addNode(from, true)
+
+		imports := map[string]bool{} // import path -> test only
+		for _, i := range pkg.Imports {
+			imports[i] = false
+		}
+		if !opts.NoTests {
+			for _, i := range append(pkg.TestImports, pkg.XTestImports...) {
+				if _, ok := imports[i]; !ok {
+					imports[i] = true
+				}
+			}
+		}
+		for to, test := range imports {
+			module := inModule(to)
+			if !module && !opts.External {
+				continue
+			}
+			if !module && opts.CollapseStd && isStdPath(to) {
+				to = "std"
+			}
+			addNode(to, module)
+			addEdge(from, to, test)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, n := range nodes {
+		g.Nodes = append(g.Nodes, *n)
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		if g.Nodes[i].Module != g.Nodes[j].Module {
+			return g.Nodes[i].Module
+		}
+		return g.Nodes[i].Path < g.Nodes[j].Path
+	})
+	for _, e := range edges {
+		g.Edges = append(g.Edges, *e)
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+	g.markCycles()
+	return g, nil
+}
+
+// isInternal returns true if p is an internal package, or is below one.
+func isInternal(p string) bool {
+	return strings.HasSuffix(p, "/internal") || strings.Contains(p, "/internal/") || strings.HasPrefix(p, "internal/")
+}
+
+// isStdPath returns true if p is the import path of a standard library package: its first element
+// has no dot.
+func isStdPath(p string) bool {
+	return !strings.Contains(strings.SplitN(p, "/", 2)[0], ".")
+}
+
+// markCycles marks the edges that are part of a cycle, these are the edges within a strongly connected
+// component (Tarjan's algorithm).
+func (g *Graph) markCycles() {
+	out := map[string][]string{}
+	for _, e := range g.Edges {
+		out[e.From] = append(out[e.From], e.To)
+	}
+	index := map[string]int{}
+	low := map[string]int{}
+	onStack := map[string]bool{}
+	comp := map[string]int{}
+	var stack []string
+	n, c := 0, 0
+
+	var connect func(v string)
+	connect = func(v string) {
+		index[v], low[v] = n, n
+		n++
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range out[v] {
+			if _, ok := index[w]; !ok {
+				connect(w)
+				if low[w] < low[v] {
+					low[v] = low[w]
+				}
+			} else if onStack[w] && index[w] < low[v] {
+				low[v] = index[w]
+			}
+		}
+		if low[v] == index[v] {
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				comp[w] = c
+				if w == v {
+					break
+				}
+			}
+			c++
+		}
+	}
+	for _, node := range g.Nodes {
+		if _, ok := index[node.Path]; !ok {
+			connect(node.Path)
+		}
+	}
+	for i, e := range g.Edges {
+		g.Edges[i].Cycle = comp[e.From] == comp[e.To]
+	}
+}
+
+// label returns the label of the node with import path p: the path relative to the module.
+func (g *Graph) label(p string) string {
+	if p == g.Module {
+		return path.Base(p)
+	}
+	return strings.TrimPrefix(p, g.Module+"/")
+}
+
+// Mermaid writes the graph as a Mermaid flowchart, in a fenced code block. Test imports are dotted
+// lines, if highlight is true internal packages and cycles are styled.
+func (g *Graph) Mermaid(w io.Writer, highlight bool) error {
+	ids := map[string]string{}
+	b := &strings.Builder{}
+	b.WriteString("```mermaid\ngraph LR\n")
+	for i, n := range g.Nodes {
+		ids[n.Path] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(b, "    %s[%q]\n", ids[n.Path], g.label(n.Path))
+	}
+	var cycles []string
+	for i, e := range g.Edges {
+		arrow := "-->"
+		if e.Test {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(b, "    %s %s %s\n", ids[e.From], arrow, ids[e.To])
+		if e.Cycle {
+			cycles = append(cycles, fmt.Sprint(i))
+		}
+	}
+	if highlight {
+		var internal []string
+		for _, n := range g.Nodes {
+			if n.Internal {
+				internal = append(internal, ids[n.Path])
+			}
+		}
+		if len(internal) > 0 {
+			b.WriteString("    classDef internal stroke-dasharray: 4 4\n")
+			fmt.Fprintf(b, "    class %s internal\n", strings.Join(internal, ","))
+		}
+		if len(cycles) > 0 {
+			fmt.Fprintf(b, "    linkStyle %s stroke:red,stroke-width:2px\n", strings.Join(cycles, ","))
+		}
+	}
+	b.WriteString("```\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// DOT writes the graph in the Graphviz DOT language. Test imports are dashed, if highlight is true
+// internal packages and cycles are styled.
+func (g *Graph) DOT(w io.Writer, highlight bool) error {
+	b := &strings.Builder{}
+	b.WriteString("digraph imports {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	for _, n := range g.Nodes {
+		attrs := []string{fmt.Sprintf("label=%q", g.label(n.Path))}
+		if !n.Module {
+			attrs = append(attrs, "color=gray")
+		}
+		if highlight && n.Internal {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(b, "\t%q [%s];\n", n.Path, strings.Join(attrs, ", "))
+	}
+	for _, e := range g.Edges {
+		var attrs []string
+		if e.Test {
+			attrs = append(attrs, "style=dashed")
+		}
+		if highlight && e.Cycle {
+			attrs = append(attrs, "color=red", "penwidth=2")
+		}
+		if len(attrs) == 0 {
+			fmt.Fprintf(b, "\t%q -> %q;\n", e.From, e.To)
+			continue
+		}
+		fmt.Fprintf(b, "\t%q -> %q [%s];\n", e.From, e.To, strings.Join(attrs, ", "))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,130 @@
+package godoc2md
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestImportGraph(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":                "module example.org/m\n",
+		"a/a.go":                "package a\n\nimport _ \"example.org/m/internal/b\"\n",
+		"a/a_test.go":           "package a_test\n\nimport _ \"example.org/m/c\"\n",
+		"internal/b/b.go":       "package b\n\nimport _ \"strings\"\n",
+		"internal/b/gen.go":     "package main\n\nimport _ \"os\"\n", // a stray command next to the package
+		"c/c.go":                "package c\n\nimport _ \"example.org/m/a\"\n",
+		"testdata/skip/skip.go": "package skip\n",
+	}
+	writeFiles(t, root, files)
+
+	g, err := ImportGraph(root, GraphOptions{External: true, CollapseStd: true}, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	g.DOT(buf, true)
+	exp := `digraph imports {
+	rankdir=LR;
+	node [shape=box];
+	"example.org/m/a" [label="a"];
+	"example.org/m/c" [label="c"];
+	"example.org/m/internal/b" [label="internal/b", style=dashed];
+	"std" [label="std", color=gray];
+	"example.org/m/a" -> "example.org/m/c" [style=dashed, color=red, penwidth=2];
+	"example.org/m/a" -> "example.org/m/internal/b";
+	"example.org/m/c" -> "example.org/m/a" [color=red, penwidth=2];
+	"example.org/m/internal/b" -> "std";
+}
+`
+	if buf.String() != exp {
+		t.Errorf("expected\n%s\ngot\n%s", exp, buf.String())
+	}
+
+	buf.Reset()
+	if err := ModuleIndex(buf, root, &Config{Graph: &GraphOptions{NoTests: true}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"| [example.org/m/internal/b](internal/b) |", "    n0 --> n2\n", "## Import graph {#pkg-imports}"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q in index, got\n%s", s, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "-.->") {
+		t.Errorf("expected no test imports, got\n%s", buf.String())
+	}
+}
+
+func TestModules(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":               "module example.org/m/v2\n",
+		"go.work":              "go 1.18\n\nuse (\n\t.\n\t./tools\n\t\"./ws\" // workspace module\n\t../outside\n)\n",
+		"m.go":                 "// Package m is the root.\npackage m\n",
+		"a/a.go":               "// Package a is in the root module.\npackage a\n\n// F is a function.\nfunc F() {}\n",
+		"tools/go.mod":         "module example.org/m/tools\n",
+		"tools/gen/main.go":    "// Gen generates.\npackage main\n\nimport _ \"example.org/m/v2/a\"\n\nfunc main() {}\n",
+		"_ws/go.mod":           "module example.org/skipped\n",
+		"ws/go.mod":            "module example.org/ws\n",
+		"ws/w.go":              "// Package ws is elsewhere.\npackage ws\n",
+		"testdata/go.mod":      "module example.org/testdata\n",
+		"testdata/testdata.go": "package testdata\n",
+	}
+	writeFiles(t, root, files)
+
+	mods, err := FindModules(root, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expMods := []Module{{"example.org/m/v2", "."}, {"example.org/m/tools", "tools"}, {"example.org/ws", "ws"}}
+	if diff := cmp.Diff(expMods, mods.Modules); diff != "" {
+		t.Errorf("unexpected modules (-want +got):\n%s", diff)
+	}
+	if mods.Import != "example.org/m/v2" || mods.Repo != "example.org/m" {
+		t.Errorf("expected import example.org/m/v2 and repo example.org/m, got %s and %s", mods.Import, mods.Repo)
+	}
+	for rel, imp := range map[string]string{".": "example.org/m/v2", "a": "example.org/m/v2/a", "tools/gen": "example.org/m/tools/gen", "ws": "example.org/ws"} {
+		if got := mods.ImportPath(rel); got != imp {
+			t.Errorf("expected import path %s for %s, got %s", imp, rel, got)
+		}
+	}
+
+	config := &Config{SrcLinkHashFormat: "#L%d", Replace: root}
+	mods.Configure("a", config)
+	buf := &bytes.Buffer{}
+	if err := Transform(buf, filepath.Join(root, "a"), config); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"`import \"example.org/m/v2/a\"`", "(https://example.org/m/blob/master/a/a.go?s=67:75#L5) {#F}", "[a.go](https://example.org/m/blob/master/a/a.go)"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q in output, got:\n%s", s, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := ModuleIndex(buf, root, &Config{Graph: &GraphOptions{}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"### example.org/m/v2\n\n| Package | Synopsis |\n|---------|----------|\n| [example.org/m/v2](.) | Package m is the root. |\n| [example.org/m/v2/a](a) | Package a is in the root module. |\n",
+		"### example.org/m/tools\n\nModule in [tools](tools).\n\n| Package | Synopsis |\n|---------|----------|\n| [example.org/m/tools/gen](tools/gen) | Command gen. Gen generates. |\n",
+		"### example.org/ws\n\nModule in [ws](ws).\n",
+		"n0[\"example.org/m/tools/gen\"]",
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q in index, got:\n%s", s, buf.String())
+		}
+	}
+
+	g, err := ImportGraph(root, GraphOptions{}, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]GraphEdge{{From: "example.org/m/tools/gen", To: "example.org/m/v2/a"}}, g.Edges); diff != "" {
+		t.Errorf("unexpected edges (-want +got):\n%s", diff)
+	}
+}
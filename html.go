@@ -0,0 +1,94 @@
+package godoc2md
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// htmlRenderer renders a standalone HTML document.
+type htmlRenderer struct{}
+
+func (htmlRenderer) Begin(w io.Writer, p Page) {
+	title := p.Name
+	if !p.Command {
+		title = "package " + p.Name
+	}
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", html.EscapeString(title))
+	if p.Synopsis != "" {
+		fmt.Fprintf(w, "<meta name=\"description\" content=\"%s\">\n", html.EscapeString(p.Synopsis))
+	}
+	io.WriteString(w, "</head>\n<body>\n")
+	if p.Command {
+		fmt.Fprintf(w, "<h1>%s</h1>\n", html.EscapeString(p.Name))
+		return
+	}
+	fmt.Fprintf(w, "<h1>%s</h1>\n<p><code>%s</code></p>\n", html.EscapeString(title), html.EscapeString(fmt.Sprintf("import %q", p.ImportPath)))
+}
+
+func (htmlRenderer) End(w io.Writer) { io.WriteString(w, "</body>\n</html>\n") }
+
+func (htmlRenderer) Heading(w io.Writer, level int, spans []Span, id string) {
+	fmt.Fprintf(w, "<h%d id=\"%s\">%s</h%d>\n", level, html.EscapeString(id), htmlSpans(spans), level)
+}
+
+func (htmlRenderer) Paragraph(w io.Writer, spans []Span) {
+	fmt.Fprintf(w, "<p>%s</p>\n", htmlSpans(spans))
+}
+
+func (htmlRenderer) Code(w io.Writer, lang, code string) {
+	class := ""
+	if lang != "" {
+		class = fmt.Sprintf(" class=\"language-%s\"", html.EscapeString(lang))
+	}
+	fmt.Fprintf(w, "<pre><code%s>%s</code></pre>\n", class, html.EscapeString(code))
+}
+
+func (htmlRenderer) List(w io.Writer, items []Item) {
+	level := 0
+	for i, it := range items {
+		for ; level < it.Level; level++ {
+			io.WriteString(w, "<ul>\n")
+		}
+		for ; level > it.Level; level-- {
+			io.WriteString(w, "</li>\n</ul>\n")
+		}
+		if i > 0 && level == it.Level && items[i-1].Level >= it.Level {
+			io.WriteString(w, "</li>\n")
+		}
+		fmt.Fprintf(w, "<li>%s", htmlSpans(it.Spans))
+	}
+	for ; level > 0; level-- {
+		io.WriteString(w, "</li>\n</ul>\n")
+	}
+}
+
+func (htmlRenderer) Table(w io.Writer, header []string, rows [][]string) {
+	io.WriteString(w, "<table>\n<tr>")
+	for _, h := range header {
+		fmt.Fprintf(w, "<th>%s</th>", html.EscapeString(h))
+	}
+	io.WriteString(w, "</tr>\n")
+	for _, row := range rows {
+		io.WriteString(w, "<tr>")
+		for _, c := range row {
+			fmt.Fprintf(w, "<td>%s</td>", html.EscapeString(c))
+		}
+		io.WriteString(w, "</tr>\n")
+	}
+	io.WriteString(w, "</table>\n")
+}
+
+// htmlSpans returns the spans as escaped HTML text and links.
+func htmlSpans(spans []Span) string {
+	b := &strings.Builder{}
+	for _, s := range spans {
+		if s.URL == "" {
+			b.WriteString(html.EscapeString(s.Text))
+			continue
+		}
+		fmt.Fprintf(b, "<a href=\"%s\">%s</a>", html.EscapeString(s.URL), html.EscapeString(s.Text))
+	}
+	return b.String()
+}
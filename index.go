@@ -0,0 +1,124 @@
+package godoc2md
+
+import (
+	"bytes"
+	"fmt"
+	"go/build"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ModuleIndex writes an index of all packages in the directory tree rooted at root to out: a table with
+// the packages and their synopsis, linking to each package's directory. The import paths are derived
+// from the go.mod files in the tree, see FindModules, or from config.Import for directories outside a
+// module. If the tree has several modules, e.g. nested go.mod files or a go.work workspace, the packages
+// are grouped by module. If config.Graph is not nil the index includes the import graph of the module as
+// a Mermaid diagram.
+func ModuleIndex(out io.Writer, root string, config *Config) error {
+	a, err := config.anchor()
+	if err != nil {
+		return err
+	}
+	mods, err := FindModules(root, config.Import)
+	if err != nil {
+		return err
+	}
+	imp := mods.Import
+	if imp == "" && len(mods.Modules) == 0 {
+		return fmt.Errorf("%s: no import path and no go.mod", root)
+	}
+
+	// rows holds the table rows of the packages of each module, by the module's directory, "" is for
+	// packages outside a module.
+	rows := map[string][]string{}
+	err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if p != root && skipDir(info.Name()) {
+			return filepath.SkipDir
+		}
+		ctxt, err := packageContext(p, config)
+		if _, ok := err.(*PackagesError); ok {
+			return err
+		}
+		if err != nil {
+			return nil
+		}
+		pkg, err := ctxt.ImportDir(p, build.ImportComment)
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		pimp := mods.ImportPath(rel)
+		synopsis := pkg.Doc
+		if pkg.Name == "main" {
+			synopsis = strings.TrimSpace("Command " + path.Base(pimp) + ". " + synopsis)
+		}
+		group := ""
+		if mod := mods.Module(rel); mod != nil {
+			group = mod.Dir
+		}
+		rows[group] = append(rows[group], fmt.Sprintf("| [%s](%s) | %s |\n", escapeMd(pimp, ctxLink), rel, escapeMd(synopsis, ctxTable)))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if imp == "" {
+		imp = mods.Modules[0].Path
+	}
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "# %s\n\n", escapeMd(imp, ctxHeading))
+	fmt.Fprintf(buf, "## Packages {#pkg-packages}\n\n")
+	const header = "| Package | Synopsis |\n|---------|----------|\n"
+	if len(rows) <= 1 {
+		buf.WriteString(header)
+		for _, r := range rows {
+			buf.WriteString(strings.Join(r, ""))
+		}
+	} else {
+		for _, mod := range append(mods.Modules, Module{}) {
+			r := rows[mod.Dir]
+			if len(r) == 0 {
+				continue
+			}
+			switch mod.Path {
+			case "":
+				buf.WriteString("### Other packages\n\n")
+			default:
+				fmt.Fprintf(buf, "### %s\n\n", escapeMd(mod.Path, ctxHeading))
+				if mod.Dir != "." {
+					fmt.Fprintf(buf, "Module in [%s](%s).\n\n", escapeMd(mod.Dir, ctxLink), mod.Dir)
+				}
+			}
+			buf.WriteString(header)
+			buf.WriteString(strings.Join(r, ""))
+			buf.WriteString("\n")
+		}
+	}
+
+	if config.Graph != nil {
+		c := *config
+		c.Import = imp
+		g, err := ImportGraph(root, *config.Graph, &c)
+		if err != nil {
+			return err
+		}
+		buf.WriteString("\n## Import graph {#pkg-imports}\n\n")
+		if err := g.Mermaid(buf, config.Graph.Highlight); err != nil {
+			return err
+		}
+	}
+
+	_, err = out.Write(shiftHeadings(anchors(normalize(buf.Bytes()), a), config.HeadingOffset))
+	return err
+}
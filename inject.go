@@ -0,0 +1,167 @@
+package godoc2md
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// markerRx matches the markers used in Inject: <!-- godoc2md:begin --> or <!-- godoc2md:begin:index -->.
+var markerRx = regexp.MustCompile(`^\s*<!--\s*godoc2md:(begin|end)(:[^ ]+)?\s*-->\s*$`)
+
+// sections maps the section names that may be used in markers to the key and text of the heading
+// that starts the section in the generated documentation. The text is used when the anchor style
+// removed the keys. The sections "type=Name", "func=Name" and "method=Type.Name" are also recognized.
+var sections = map[string][2]string{
+	"overview":       {"pkg-overview", "Overview"},
+	"usage":          {"pkg-usage", "Usage"},
+	"index":          {"pkg-index", "Index"},
+	"examples":       {"pkg-examples", "Examples"},
+	"files":          {"pkg-files", "Package files"},
+	"constants":      {"pkg-constants", "Constants"},
+	"variables":      {"pkg-variables", "Variables"},
+	"subdirectories": {"pkg-subdirectories", "Subdirectories"},
+}
+
+// HasMarkers returns true if readme contains at least one godoc2md begin marker.
+func HasMarkers(readme []byte) bool {
+	for _, line := range strings.Split(string(readme), "\n") {
+		if m := markerRx.FindStringSubmatch(line); m != nil && m[1] == "begin" {
+			return true
+		}
+	}
+	return false
+}
+
+// Inject replaces the content between the markers <!-- godoc2md:begin --> and <!-- godoc2md:end -->
+// in readme with the generated documentation in doc. Everything outside the markers is left untouched.
+// A begin marker may name a section, <!-- godoc2md:begin:index -->, then only that part of doc is
+// inserted, see sections for the names that can be used. The headings in doc are shifted so that they
+// fall under the heading that precedes the begin marker.
+func Inject(readme, doc []byte) ([]byte, error) {
+	out := &bytes.Buffer{}
+	lines := strings.SplitAfter(string(readme), "\n")
+	level := 0
+	fence := ""
+	prev := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		out.WriteString(line)
+
+		if fence = fenced(line, fence); fence != "" {
+			prev = ""
+			continue
+		}
+		if l := headingLevel(line, prev); l > 0 {
+			level = l
+		}
+		prev = line
+
+		m := markerRx.FindStringSubmatch(strings.TrimSuffix(line, "\n"))
+		if m == nil {
+			continue
+		}
+		if m[1] == "end" {
+			return nil, fmt.Errorf("line %d: end marker without begin marker", i+1)
+		}
+		name := strings.TrimPrefix(m[2], ":")
+
+		// find the end marker, skip everything in between
+		j := i + 1
+		for ; j < len(lines); j++ {
+			if e := markerRx.FindStringSubmatch(strings.TrimSuffix(lines[j], "\n")); e != nil {
+				if e[1] == "begin" {
+					return nil, fmt.Errorf("line %d: begin marker before end of section on line %d", j+1, i+1)
+				}
+				if e[2] != "" && e[2] != m[2] {
+					return nil, fmt.Errorf("line %d: end marker %q does not match begin marker %q", j+1, e[2][1:], name)
+				}
+				break
+			}
+		}
+		if j == len(lines) {
+			return nil, fmt.Errorf("line %d: begin marker without end marker", i+1)
+		}
+
+		section := doc
+		if name != "" {
+			var err error
+			if section, err = docSection(doc, name); err != nil {
+				return nil, fmt.Errorf("line %d: %s", i+1, err)
+			}
+		}
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteByte('\n')
+		}
+		out.WriteByte('\n')
+		section = bytes.TrimSpace(shiftHeadings(section, level-minHeadingLevel(section)+1))
+		if len(section) > 0 {
+			out.Write(section)
+			out.WriteString("\n\n")
+		}
+		out.WriteString(lines[j])
+		i = j
+	}
+	return out.Bytes(), nil
+}
+
+// docSection returns the section name from the generated documentation doc: the heading with the
+// section's anchor and everything up to the next heading of the same or a higher level.
+func docSection(doc []byte, name string) ([]byte, error) {
+	section, ok := sections[name]
+	texts := []string{section[1]}
+	if !ok {
+		kind, id := "", ""
+		if i := strings.Index(name, "="); i > 0 {
+			kind, id = name[:i], name[i+1:]
+		}
+		switch kind {
+		case "type", "func":
+			texts = []string{kind + " " + id}
+		case "method":
+			recv := strings.SplitN(id, ".", 2)
+			if len(recv) != 2 {
+				return nil, fmt.Errorf("method section %q must be of the form Type.Name", name)
+			}
+			texts = []string{"func (" + recv[0] + ") " + recv[1], "func (*" + recv[0] + ") " + recv[1]}
+		default:
+			return nil, fmt.Errorf("unknown section %q", name)
+		}
+		section[0] = id
+	}
+
+	lines := strings.SplitAfter(string(doc), "\n")
+	fence := ""
+	start, level := -1, 0
+	for i, line := range lines {
+		if fence = fenced(line, fence); fence != "" {
+			continue
+		}
+		l := headingLevel(line, "")
+		if l == 0 {
+			continue
+		}
+		if start >= 0 && l <= level {
+			return []byte(strings.Join(lines[start:i], "")), nil
+		}
+		if start >= 0 {
+			continue
+		}
+		match := false
+		if m := headingIDRx.FindStringSubmatch(line); m != nil && m[1] == section[0] {
+			match = true
+		}
+		text := sinceRx.ReplaceAllString(platformsRx.ReplaceAllString(headingText(line), ""), "")
+		for _, t := range texts {
+			match = match || text == t
+		}
+		if match {
+			start, level = i, l
+		}
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("section %q not found", name)
+	}
+	return []byte(strings.Join(lines[start:], "")), nil
+}
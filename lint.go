@@ -0,0 +1,358 @@
+package godoc2md
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LintIssue is a problem with the documentation of a package.
+type LintIssue struct {
+	Pos     string `json:"pos"`              // Position in the source, file:line.
+	Symbol  string `json:"symbol,omitempty"` // Symbol the issue is about, empty for the package.
+	Message string `json:"message"`
+}
+
+// LintReport is the result of linting the documentation of a package.
+type LintReport struct {
+	ImportPath string      `json:"import_path"`
+	Dir        string      `json:"dir"`
+	Symbols    int         `json:"symbols"`    // Exported symbols.
+	Documented int         `json:"documented"` // Exported symbols with a doc comment.
+	Examples   int         `json:"examples"`
+	Coverage   float64     `json:"coverage"` // Percentage of exported symbols that are documented.
+	Issues     []LintIssue `json:"issues"`
+}
+
+var (
+	deprecatedRx       = regexp.MustCompile(`(?i)\bdeprecated\b`)
+	deprecatedParaRx   = regexp.MustCompile(`^Deprecated: \S`)
+	deprecatedMarkerRx = regexp.MustCompile(`(?i)^deprecated\s*($|[:-])`)
+)
+
+// linter collects the issues of a package.
+type linter struct {
+	fset   *token.FileSet
+	links  *docLinks
+	report *LintReport
+}
+
+func (l *linter) issue(pos token.Pos, symbol, format string, args ...interface{}) {
+	p := l.fset.Position(pos)
+	l.report.Issues = append(l.report.Issues, LintIssue{
+		Pos:     fmt.Sprintf("%s:%d", p.Filename, p.Line),
+		Symbol:  symbol,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Lint checks the documentation of the package in path, which is loaded like Transform does. It reports
+// exported symbols without a doc comment, doc comments that don't start with the symbol's name, a missing
+// package comment, doc links that can't be resolved, malformed deprecation notices and examples
+// whose name doesn't match a symbol.
+func Lint(path string, config *Config) (*LintReport, error) {
+	fs, pres := presentation(config)
+	info, err := load(fs, pres, path, config)
+	if err != nil {
+		return nil, err
+	}
+	if info.PDoc == nil {
+		return nil, fmt.Errorf("%s: no package", path)
+	}
+	pdoc := info.PDoc
+	l := &linter{fset: info.FSet, links: newDocLinks(info), report: &LintReport{ImportPath: pdoc.ImportPath, Dir: path}}
+
+	if pdoc.Doc == "" {
+		pos := ""
+		if len(pdoc.Filenames) > 0 {
+			pos = filepath.Join(path, filepath.Base(pdoc.Filenames[0])) + ":1"
+		}
+		l.report.Issues = append(l.report.Issues, LintIssue{Pos: pos, Message: "package should have a package comment"})
+	} else {
+		l.comment(token.NoPos, "", pdoc.Doc)
+	}
+
+	l.values(pdoc.Consts)
+	l.values(pdoc.Vars)
+	l.funcs(pdoc.Funcs, "")
+	for _, t := range pdoc.Types {
+		l.symbol(t.Decl, t.Name, t.Doc, true)
+		l.values(t.Consts)
+		l.values(t.Vars)
+		l.funcs(t.Funcs, "")
+		l.funcs(t.Methods, t.Name)
+	}
+
+	// godoc drops the examples that don't match a symbol, so parse them here
+	examples, fset, err := parseExamples(path, config)
+	if err != nil {
+		return nil, err
+	}
+	for _, ex := range examples {
+		l.report.Examples++
+		if !l.exampleMatches(ex.Name) {
+			p := fset.Position(ex.Code.Pos())
+			l.report.Issues = append(l.report.Issues, LintIssue{
+				Pos:     fmt.Sprintf("%s:%d", p.Filename, p.Line),
+				Symbol:  "Example" + ex.Name,
+				Message: fmt.Sprintf("example Example%s refers to unknown identifier %q", ex.Name, ex.Name),
+			})
+		}
+	}
+
+	l.report.Coverage = coverage(l.report.Documented, l.report.Symbols)
+	sort.SliceStable(l.report.Issues, func(i, j int) bool { return posLess(l.report.Issues[i].Pos, l.report.Issues[j].Pos) })
+	return l.report, nil
+}
+
+// parseExamples returns the examples in the test files of the package in dir.
+func parseExamples(dir string, config *Config) ([]*doc.Example, *token.FileSet, error) {
+	fset := token.NewFileSet()
+	ctxt, err := packageContext(dir, config)
+	if err != nil {
+		return nil, fset, err
+	}
+	pkg, err := ctxt.ImportDir(dir, 0)
+	if err != nil {
+		return nil, fset, err
+	}
+	var files []*ast.File
+	for _, name := range append(pkg.TestGoFiles, pkg.XTestGoFiles...) {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, fset, err
+		}
+		files = append(files, f)
+	}
+	return doc.Examples(files...), fset, nil
+}
+
+// posLess compares the positions a and b, file:line, numerically by line.
+func posLess(a, b string) bool {
+	fa, la := splitPos(a)
+	fb, lb := splitPos(b)
+	if fa != fb {
+		return fa < fb
+	}
+	return la < lb
+}
+
+func splitPos(pos string) (string, int) {
+	i := strings.LastIndex(pos, ":")
+	if i < 0 {
+		return pos, 0
+	}
+	line, err := strconv.Atoi(pos[i+1:])
+	if err != nil {
+		return pos, 0
+	}
+	return pos[:i], line
+}
+
+// exampleMatches returns true if the example name, without the "Example" prefix, refers to the package
+// or a symbol in it: "", "F", "T" or "T_M", optionally followed by "_suffix" where suffix starts with a
+// lowercase letter.
+func (l *linter) exampleMatches(name string) bool {
+	if i := strings.LastIndex(name, "_"); i >= 0 {
+		if r, _ := utf8.DecodeRuneInString(name[i+1:]); unicode.IsLower(r) {
+			name = name[:i]
+		}
+	}
+	if name == "" {
+		return true
+	}
+	_, ok := l.links.keys[strings.Replace(name, "_", ".", 1)]
+	return ok
+}
+
+// symbol checks the doc comment of the exported symbol name declared in decl.
+func (l *linter) symbol(decl ast.Node, name, comment string, article bool) {
+	if !ast.IsExported(name) {
+		return
+	}
+	l.report.Symbols++
+	if comment == "" {
+		l.issue(decl.Pos(), name, "exported %s should have a doc comment", name)
+		return
+	}
+	l.report.Documented++
+	first := strings.Fields(comment)
+	if article && len(first) > 1 && (first[0] == "A" || first[0] == "An" || first[0] == "The") {
+		first = first[1:]
+	}
+	short := name[strings.LastIndex(name, ".")+1:]
+	if len(first) == 0 || (first[0] != short && !strings.HasPrefix(first[0], short+"'")) {
+		l.issue(decl.Pos(), name, "doc comment of %s should start with %q", name, short+" ")
+	}
+	l.comment(decl.Pos(), name, comment)
+}
+
+func (l *linter) funcs(fs []*doc.Func, recv string) {
+	for _, f := range fs {
+		name := f.Name
+		if recv != "" {
+			name = recv + "." + f.Name
+		}
+		l.symbol(f.Decl, name, f.Doc, false)
+	}
+}
+
+// values checks a group of constants or variables, a doc comment on the group documents all of them,
+// otherwise each needs its own comment.
+func (l *linter) values(vs []*doc.Value) {
+	for _, v := range vs {
+		for _, spec := range v.Decl.Specs {
+			vspec := spec.(*ast.ValueSpec)
+			for _, n := range vspec.Names {
+				if !n.IsExported() {
+					continue
+				}
+				l.report.Symbols++
+				if v.Doc != "" || vspec.Doc != nil || vspec.Comment != nil {
+					l.report.Documented++
+					continue
+				}
+				l.issue(n.Pos(), n.Name, "exported %s should have a doc comment or be in a documented group", n.Name)
+			}
+		}
+		if v.Doc != "" {
+			l.comment(v.Decl.Pos(), strings.Join(v.Names, ", "), v.Doc)
+		}
+	}
+}
+
+// comment checks the doc links and Deprecated paragraphs of a comment.
+func (l *linter) comment(pos token.Pos, symbol, comment string) {
+	for _, line := range strings.Split(comment, "\n") {
+		for i := strings.IndexByte(line, '['); i >= 0 && i < len(line); {
+			m := docLinkRx.FindString(line[i:])
+			if m != "" && !linkFollows(line[i+len(m):]) {
+				name := m[1 : len(m)-1]
+				r, _ := utf8.DecodeRuneInString(strings.TrimPrefix(name, "*"))
+				if (unicode.IsUpper(r) || strings.ContainsAny(name, "./")) && l.links.url(name) == "" {
+					l.issueAt(pos, symbol, "doc link %s does not resolve", m)
+				}
+			}
+			j := strings.IndexByte(line[i+1:], '[')
+			if j < 0 {
+				break
+			}
+			i += j + 1
+		}
+	}
+	for _, para := range strings.Split(comment, "\n\n") {
+		para = strings.TrimSpace(para)
+		loc := deprecatedRx.FindStringIndex(para)
+		if loc == nil {
+			continue
+		}
+		if loc[0] == 0 && deprecatedParaRx.MatchString(para) {
+			continue
+		}
+		// "deprecated" used as a word in a sentence is fine, only flag notices: the word on its own or
+		// followed by a colon or dash
+		if loc[0] == 0 && !deprecatedMarkerRx.MatchString(para) {
+			continue
+		}
+		if loc[0] > 0 && !strings.HasPrefix(para[loc[1]:], ":") {
+			continue
+		}
+		l.issueAt(pos, symbol, "deprecation notice should be a paragraph of its own starting with \"Deprecated: \"")
+	}
+}
+
+// issueAt is issue for a position that may be unknown, the package comment.
+func (l *linter) issueAt(pos token.Pos, symbol, format string, args ...interface{}) {
+	if pos == token.NoPos {
+		l.report.Issues = append(l.report.Issues, LintIssue{Pos: l.report.Dir, Symbol: symbol, Message: fmt.Sprintf(format, args...)})
+		return
+	}
+	l.issue(pos, symbol, format, args...)
+}
+
+// coverage returns the percentage of symbols that are documented, 100 if there are no symbols.
+func coverage(documented, symbols int) float64 {
+	if symbols == 0 {
+		return 100
+	}
+	return 100 * float64(documented) / float64(symbols)
+}
+
+// LintTotal returns a report that sums the symbols, documented symbols and examples of reports, e.g. for
+// all the packages of a repository. The issues are not copied.
+func LintTotal(importPath string, reports []*LintReport) *LintReport {
+	total := &LintReport{ImportPath: importPath}
+	for _, r := range reports {
+		total.Symbols += r.Symbols
+		total.Documented += r.Documented
+		total.Examples += r.Examples
+	}
+	total.Coverage = coverage(total.Documented, total.Symbols)
+	return total
+}
+
+// LintText writes the issues of the reports to w, one per line, followed by the coverage of each package.
+func LintText(w io.Writer, reports []*LintReport) error {
+	for _, r := range reports {
+		for _, i := range r.Issues {
+			if _, err := fmt.Fprintf(w, "%s: %s\n", i.Pos, i.Message); err != nil {
+				return err
+			}
+		}
+	}
+	for _, r := range reports {
+		if _, err := fmt.Fprintf(w, "%s: %.1f%% documented (%d of %d), %d examples\n", r.ImportPath, r.Coverage, r.Documented, r.Symbols, r.Examples); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LintJSON writes the reports to w as JSON.
+func LintJSON(w io.Writer, reports []*LintReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reports)
+}
+
+// LintMarkdown writes a markdown summary of the reports to w: a table with the coverage of each package
+// followed by the issues per package.
+func LintMarkdown(w io.Writer, reports []*LintReport, config *Config) error {
+	a, err := config.anchor()
+	if err != nil {
+		return err
+	}
+	b := &strings.Builder{}
+	b.WriteString("# Documentation coverage {#lint}\n\n")
+	b.WriteString("| Package | Coverage | Documented | Examples | Issues |\n")
+	b.WriteString("|---------|---------:|-----------:|---------:|-------:|\n")
+	for _, r := range reports {
+		fmt.Fprintf(b, "| %s | %.1f%% | %d/%d | %d | %d |\n", escapeMd(r.ImportPath, ctxTable), r.Coverage, r.Documented, r.Symbols, r.Examples, len(r.Issues))
+	}
+	if len(reports) > 1 {
+		total := LintTotal("", reports)
+		fmt.Fprintf(b, "| **Total** | %.1f%% | %d/%d | %d | |\n", total.Coverage, total.Documented, total.Symbols, total.Examples)
+	}
+	for _, r := range reports {
+		if len(r.Issues) == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "\n## %s {#lint-%s}\n\n", escapeMd(r.ImportPath, ctxHeading), strings.TrimPrefix(headingKey(r.ImportPath), "hdr-"))
+		for _, i := range r.Issues {
+			fmt.Fprintf(b, "* %s: %s\n", codeSpan(i.Pos), escapeMd(i.Message, ctxPara))
+		}
+	}
+	_, err = w.Write(shiftHeadings(anchors(normalize([]byte(b.String())), a), config.HeadingOffset))
+	return err
+}
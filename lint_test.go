@@ -0,0 +1,90 @@
+package godoc2md
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLint(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"p.go": `package p
+
+// F does things, see [G] and [Missing].
+func F() {}
+
+func G() {}
+
+// Returns a T.
+func NewT() T { return T{} }
+
+// A T is a thing.
+type T struct{}
+
+// M is old. Deprecated: use N.
+func (T) M() {}
+
+// N is new.
+//
+// Deprecated APIs, like M, are kept for compatibility.
+func (T) N() {}
+
+// O is old.
+//
+// Deprecated - use N.
+func (T) O() {}
+
+// Exported constants.
+const (
+	A = 1
+	B = 2
+)
+
+var V = 1
+`,
+		"p_test.go": `package p
+
+func ExampleF() {}
+
+func ExampleT_M_second() {}
+
+func ExampleH() {}
+`,
+	}
+	writeFiles(t, dir, files)
+	r, err := Lint(dir, &Config{Import: "example.org/p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, i := range r.Issues {
+		got = append(got, strings.TrimPrefix(i.Pos, dir+"/")+": "+i.Message)
+	}
+	exp := []string{
+		"p.go:1: package should have a package comment",
+		"p.go:4: doc link [Missing] does not resolve",
+		"p.go:6: exported G should have a doc comment",
+		"p.go:9: doc comment of NewT should start with \"NewT \"",
+		"p.go:15: deprecation notice should be a paragraph of its own starting with \"Deprecated: \"",
+		"p.go:25: deprecation notice should be a paragraph of its own starting with \"Deprecated: \"",
+		"p.go:33: exported V should have a doc comment or be in a documented group",
+		"p_test.go:7: example ExampleH refers to unknown identifier \"H\"",
+	}
+	if diff := cmp.Diff(exp, got); diff != "" {
+		t.Errorf("unexpected issues (-want +got):\n%s", diff)
+	}
+	if r.Symbols != 10 || r.Documented != 8 || r.Examples != 3 {
+		t.Errorf("expected 8 of 10 symbols documented and 3 examples, got %d of %d and %d", r.Documented, r.Symbols, r.Examples)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := LintMarkdown(buf, []*LintReport{r}, &Config{}); err != nil {
+		t.Fatal(err)
+	}
+	if s := "| example.org/p | 80.0% | 8/10 | 3 | 8 |\n"; !strings.Contains(buf.String(), s) {
+		t.Errorf("expected %q in summary, got\n%s", s, buf.String())
+	}
+}
@@ -1,18 +1,27 @@
 package godoc2md
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"strings"
 	"text/template"
 
 	"golang.org/x/tools/godoc"
 	"golang.org/x/tools/godoc/vfs"
 )
 
-// write writes the godoc in pres to w.
-func write(w io.Writer, fs vfs.NameSpace, pres *godoc.Presentation, tmpl *template.Template, path, imp string) error {
-	fs.Bind(path, vfs.OS(path), "/", vfs.BindReplace) // ??
-	info := pres.GetPkgPageInfo(path, imp, 0)
+// load loads the package in path and returns the godoc page info for it.
+func load(fs vfs.NameSpace, pres *godoc.Presentation, path string, config *Config) (*godoc.PageInfo, error) {
+	return loadFrom(fs, pres, path, osFS(path), config)
+}
+
+// loadFrom loads the package in src, which is bound at path in fs, and returns the godoc page info for it.
+func loadFrom(fs vfs.NameSpace, pres *godoc.Presentation, path string, src vfs.FileSystem, config *Config) (*godoc.PageInfo, error) {
+	var info *godoc.PageInfo
+	if err := withBuildContext(fs, path, src, config, func() { info = pres.GetPkgPageInfo(path, config.Import, 0) }); err != nil {
+		return nil, err
+	}
 
 	/*
 		for i := range info.Examples {
@@ -31,10 +40,136 @@ func write(w io.Writer, fs vfs.NameSpace, pres *godoc.Presentation, tmpl *templa
 	*/
 
 	if info == nil {
-		return fmt.Errorf("%s: no such directory or package", path)
+		return nil, fmt.Errorf("%s: no such directory or package", path)
 	}
 	if info.Err != nil {
-		return info.Err
+		return nil, info.Err
+	}
+	return info, nil
+}
+
+// write writes the godoc in info to w.
+func write(w io.Writer, tmpl *template.Template, info *godoc.PageInfo, config *Config) error {
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, info); err != nil {
+		return err
+	}
+	a, err := config.anchor()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(shiftHeadings(anchors(normalize(buf.Bytes()), a), config.HeadingOffset))
+	return err
+}
+
+// normalize cleans up the template output: trailing white space is removed, runs of blank lines are
+// collapsed into a single one and the output ends in exactly one newline. Fenced code blocks are left
+// alone, except for the trailing white space. This makes the output stable and suitable for committing.
+func normalize(buf []byte) []byte {
+	out := &bytes.Buffer{}
+	blank := true // skip leading blank lines
+	fence := ""
+	for _, line := range strings.Split(string(buf), "\n") {
+		line = strings.TrimRight(line, " \t")
+		if fence == "" && line == "" {
+			blank = true
+			continue
+		}
+		if blank && out.Len() > 0 && fence == "" {
+			out.WriteByte('\n')
+		}
+		blank = false
+		fence = fenced(line, fence)
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+// fenced returns the fence that is open after line, given that fence is currently open. An empty string
+// means no fenced code block is open.
+func fenced(line, fence string) string {
+	if len(line)-len(strings.TrimLeft(line, " ")) > 3 {
+		return fence // indented code
+	}
+	trimmed := strings.TrimLeft(strings.TrimRight(line, " \t\n"), " ")
+	if fence != "" {
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			return ""
+		}
+		return fence
+	}
+	for _, c := range []string{"`", "~"} {
+		n := len(trimmed) - len(strings.TrimLeft(trimmed, c))
+		if n >= 3 {
+			return strings.Repeat(c, n)
+		}
+	}
+	return ""
+}
+
+// headingLevel returns the level of the heading on line or 0 if line isn't a heading. Prev is the line
+// before it and is used to detect setext headings, it may be empty.
+func headingLevel(line, prev string) int {
+	line = strings.TrimRight(line, " \t\n")
+	n := len(line) - len(strings.TrimLeft(line, "#"))
+	if n > 0 && n <= 6 && (len(line) == n || line[n] == ' ') {
+		return n
+	}
+	if strings.TrimSpace(prev) == "" || headingLevel(prev, "") > 0 || line == "" {
+		return 0
+	}
+	switch strings.Trim(line, " ") {
+	case strings.Repeat("=", len(strings.Trim(line, " "))):
+		return 1
+	case strings.Repeat("-", len(strings.Trim(line, " "))):
+		return 2
+	}
+	return 0
+}
+
+// minHeadingLevel returns the lowest heading level used in buf, or 1 if buf has no headings.
+func minHeadingLevel(buf []byte) int {
+	min := 0
+	fence := ""
+	for _, line := range strings.Split(string(buf), "\n") {
+		if fence = fenced(line, fence); fence != "" {
+			continue
+		}
+		if l := headingLevel(line, ""); l > 0 && (min == 0 || l < min) {
+			min = l
+		}
+	}
+	if min == 0 {
+		return 1
+	}
+	return min
+}
+
+// shiftHeadings shifts all ATX headings in buf n levels down, headings will not go beyond level 6.
+// A negative n shifts the headings up, but not beyond level 1.
+func shiftHeadings(buf []byte, n int) []byte {
+	if n == 0 {
+		return buf
+	}
+	lines := strings.SplitAfter(string(buf), "\n")
+	fence := ""
+	for i, line := range lines {
+		if fence = fenced(line, fence); fence != "" {
+			continue
+		}
+		l := headingLevel(line, "")
+		if l == 0 {
+			continue
+		}
+		nl := l + n
+		if nl > 6 {
+			nl = 6
+		}
+		if nl < 1 {
+			nl = 1
+		}
+		lines[i] = strings.Repeat("#", nl) + line[l:]
 	}
-	return tmpl.Execute(w, info)
+	return []byte(strings.Join(lines, ""))
 }
@@ -0,0 +1,232 @@
+package godoc2md
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	pathpkg "path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// CachedPackage is a package in the module cache, see LookupModCache.
+type CachedPackage struct {
+	ImportPath string // Import path of the package.
+	Module     string // Path of the module the package is in.
+	Version    string // Version of the module.
+	Dir        string // Directory of the package, if the module is extracted in the cache.
+	Zip        string // Zip file of the module in cache/download, if it isn't extracted.
+}
+
+// LookupModCache finds the package pkg, written as import/path@version or as a bare import path, in the
+// module cache in the directory cache, or in $GOMODCACHE when cache is empty. Modules that are only
+// downloaded, as a zip in cache/download, are found too. Without a version the latest release in the
+// cache is used, or if there is none, the latest pre-release or pseudo-version. Nothing is downloaded.
+func LookupModCache(pkg, cache string) (*CachedPackage, error) {
+	if cache == "" {
+		var err error
+		if cache, err = modCacheDir(); err != nil {
+			return nil, err
+		}
+	}
+	imp, version := pkg, ""
+	if i := strings.LastIndex(pkg, "@"); i >= 0 {
+		imp, version = pkg[:i], pkg[i+1:]
+	}
+	if imp == "" || (version == "" && strings.HasSuffix(pkg, "@")) {
+		return nil, fmt.Errorf("malformed package: %q", pkg)
+	}
+
+	// The longest module path that has the package wins, as the go command does.
+	for mod := imp; mod != "." && mod != "/"; mod = pathpkg.Dir(mod) {
+		versions := cachedVersions(cache, mod)
+		if version != "" {
+			versions = filterVersion(versions, version)
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(imp, mod), "/")
+		for i := len(versions) - 1; i >= 0; i-- {
+			p := &CachedPackage{ImportPath: imp, Module: mod, Version: versions[i]}
+			if p.find(cache, rel) {
+				return p, nil
+			}
+		}
+	}
+	if version != "" {
+		return nil, fmt.Errorf("%s: not found in the module cache %s", pkg, cache)
+	}
+	return nil, fmt.Errorf("%s: no version found in the module cache %s", pkg, cache)
+}
+
+// find looks for the package in the directory rel of the module p in the cache, in the extracted
+// module first and then in the module zip. It sets p.Dir or p.Zip and returns true if the package is
+// found.
+func (p *CachedPackage) find(cache, rel string) bool {
+	dir := filepath.Join(cache, modEscape(p.Module)+"@"+modEscape(p.Version), filepath.FromSlash(rel))
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*.go")); len(matches) > 0 {
+		p.Dir = dir
+		return true
+	}
+	file := filepath.Join(cache, "cache", "download", modEscape(p.Module), "@v", modEscape(p.Version)+".zip")
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return false
+	}
+	defer zr.Close()
+	prefix := pathpkg.Join(p.Module+"@"+p.Version, rel)
+	for _, f := range zr.File {
+		if pathpkg.Dir(f.Name) == prefix && strings.HasSuffix(f.Name, ".go") {
+			p.Zip = file
+			return true
+		}
+	}
+	return false
+}
+
+// Configure sets up config to document the package: the import path, and the source links, which
+// point at the module's repository at the tag, or commit, of the version. The repository is the module
+// path without its major version suffix.
+func (p *CachedPackage) Configure(config *Config) {
+	config.Import = p.ImportPath
+	config.SubPackage = strings.TrimPrefix(strings.TrimPrefix(p.ImportPath, p.Module), "/")
+	config.GitRef = gitRef(p.Version)
+	config.Repo = stripMajor(p.Module) // major version branch, e.g. v2.3.0 of example.com/repo/v2
+	if p.Dir != "" {
+		config.Replace = strings.TrimSuffix(p.Dir, string(filepath.Separator)+filepath.FromSlash(config.SubPackage))
+		return
+	}
+	config.Replace = p.Module + "@" + p.Version
+}
+
+// TransformCached is like Transform, but for a package in the module cache. Use Configure to set up
+// config for it.
+func TransformCached(out io.Writer, p *CachedPackage, config *Config) error {
+	if p.Dir != "" {
+		return Transform(out, p.Dir, config)
+	}
+	zr, err := zip.OpenReader(p.Zip)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	rel := strings.TrimPrefix(strings.TrimPrefix(p.ImportPath, p.Module), "/")
+	return TransformFS(out, &zr.Reader, pathpkg.Join(p.Module+"@"+p.Version, rel), config)
+}
+
+// modCacheDir returns the module cache directory of the go command.
+func modCacheDir() (string, error) {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir, nil
+	}
+	out, err := exec.Command("go", "env", "GOMODCACHE").Output()
+	if err != nil {
+		return "", fmt.Errorf("can't find the module cache: %s", err)
+	}
+	dir := strings.TrimSpace(string(out))
+	if dir == "" {
+		return "", fmt.Errorf("can't find the module cache: GOMODCACHE is not set")
+	}
+	return dir, nil
+}
+
+// cachedVersions returns the versions of the module mod in the module cache, extracted or downloaded,
+// oldest first.
+func cachedVersions(cache, mod string) []string {
+	seen := map[string]bool{}
+	esc := modEscape(mod)
+	dirs, _ := filepath.Glob(filepath.Join(cache, esc+"@*"))
+	for _, d := range dirs {
+		v := filepath.Base(d)
+		seen[modUnescape(v[strings.LastIndex(v, "@")+1:])] = true
+	}
+	zips, _ := filepath.Glob(filepath.Join(cache, "cache", "download", esc, "@v", "*.zip"))
+	for _, z := range zips {
+		seen[modUnescape(strings.TrimSuffix(filepath.Base(z), ".zip"))] = true
+	}
+	versions := make([]string, 0, len(seen))
+	for v := range seen {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versionLess(versions[i], versions[j]) })
+	return versions
+}
+
+// filterVersion returns version if it is in versions.
+func filterVersion(versions []string, version string) []string {
+	for _, v := range versions {
+		if v == version {
+			return []string{v}
+		}
+	}
+	return nil
+}
+
+// versionLess orders module versions: pre-releases and pseudo-versions, by name, before releases, by
+// semver.
+func versionLess(a, b string) bool {
+	a, b = strings.TrimSuffix(a, "+incompatible"), strings.TrimSuffix(b, "+incompatible")
+	ra, rb := semverRx.MatchString(a), semverRx.MatchString(b)
+	switch {
+	case ra && rb:
+		return semverLess(a, b)
+	case ra != rb:
+		return rb
+	}
+	return a < b
+}
+
+// pseudoRx matches a pseudo-version, the last group is the commit.
+var pseudoRx = regexp.MustCompile(`^v[0-9]+\.[0-9]+\.[0-9]+-(?:.*\.)?[0-9]{14}-([0-9a-f]{12})(?:\+incompatible)?$`)
+
+// gitRef returns the git tag, or commit for a pseudo-version, of the module version v.
+func gitRef(v string) string {
+	if m := pseudoRx.FindStringSubmatch(v); m != nil {
+		return m[1]
+	}
+	return strings.TrimSuffix(v, "+incompatible")
+}
+
+// stripMajor returns p without its major version suffix: example.com/repo/v2 becomes example.com/repo.
+func stripMajor(p string) string {
+	dir, elem := pathpkg.Split(p)
+	if dir == "" || !majorRx.MatchString(elem) || elem == "v0" || elem == "v1" {
+		return p
+	}
+	return strings.TrimSuffix(dir, "/")
+}
+
+// modEscape escapes a module path or version as the module cache does: upper case letters become an
+// exclamation mark followed by the letter in lower case.
+func modEscape(s string) string {
+	b := &strings.Builder{}
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			b.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// modUnescape reverses modEscape.
+func modUnescape(s string) string {
+	b := &strings.Builder{}
+	bang := false
+	for _, r := range s {
+		switch {
+		case r == '!':
+			bang = true
+			continue
+		case bang:
+			r = unicode.ToUpper(r)
+		}
+		bang = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
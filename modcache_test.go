@@ -0,0 +1,74 @@
+package godoc2md
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLookupModCache(t *testing.T) {
+	cache := t.TempDir()
+	src := "// Package sub is cached.\npackage sub\n\n// F is a function.\nfunc F() {}\n"
+	// an extracted module, with an upper case letter in its path
+	dir := filepath.Join(cache, "example.com", "!foo@v1.0.0", "sub")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// downloaded modules, one in several versions and one with a major version suffix
+	for _, v := range []string{"example.com/bar@v1.2.0", "example.com/bar@v1.10.0", "example.com/bar@v0.0.0-20220114203417-14399d5448c4", "example.com/qux/v2@v2.1.0"} {
+		i := strings.Index(v, "@")
+		mod, version := v[:i], v[i+1:]
+		zdir := filepath.Join(cache, "cache", "download", filepath.FromSlash(mod), "@v")
+		if err := os.MkdirAll(zdir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		zw := zip.NewWriter(buf)
+		w, _ := zw.Create(v + "/sub/sub.go")
+		w.Write([]byte(src))
+		zw.Close()
+		if err := os.WriteFile(filepath.Join(zdir, version+".zip"), buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		pkg, link string
+	}{
+		{"example.com/Foo/sub@v1.0.0", "(https://example.com/Foo/blob/v1.0.0/sub/sub.go?s=59:67#L5) {#F}"},
+		{"example.com/Foo/sub", "(https://example.com/Foo/blob/v1.0.0/sub/sub.go?s=59:67#L5) {#F}"},
+		{"example.com/bar/sub", "(https://example.com/bar/blob/v1.10.0/sub/sub.go?s=59:67#L5) {#F}"},
+		{"example.com/bar/sub@v1.2.0", "(https://example.com/bar/blob/v1.2.0/sub/sub.go?s=59:67#L5) {#F}"},
+		{"example.com/bar/sub@v0.0.0-20220114203417-14399d5448c4", "(https://example.com/bar/blob/14399d5448c4/sub/sub.go?s=59:67#L5) {#F}"},
+		{"example.com/qux/v2/sub", "(https://example.com/qux/blob/v2.1.0/sub/sub.go?s=59:67#L5) {#F}"},
+	}
+	for _, tc := range tests {
+		p, err := LookupModCache(tc.pkg, cache)
+		if err != nil {
+			t.Errorf("%s: %s", tc.pkg, err)
+			continue
+		}
+		config := &Config{SrcLinkHashFormat: "#L%d"}
+		p.Configure(config)
+		buf := &bytes.Buffer{}
+		if err := TransformCached(buf, p, config); err != nil {
+			t.Errorf("%s: %s", tc.pkg, err)
+			continue
+		}
+		if !strings.Contains(buf.String(), tc.link) {
+			t.Errorf("%s: expected %q in output, got:\n%s", tc.pkg, tc.link, buf.String())
+		}
+	}
+
+	for _, pkg := range []string{"example.com/bar/sub@v1.3.0", "example.com/bar/other", "example.com/baz"} {
+		if _, err := LookupModCache(pkg, cache); err == nil {
+			t.Errorf("%s: expected an error", pkg)
+		}
+	}
+}
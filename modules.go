@@ -0,0 +1,184 @@
+package godoc2md
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Module is a module in a directory tree, see FindModules.
+type Module struct {
+	Path string // Module path from the go.mod, e.g. example.com/repo/tools or example.com/repo/v2.
+	Dir  string // Directory of the go.mod relative to the root of the tree, slash separated, "." for the root.
+}
+
+// Modules are the modules in a directory tree, e.g. a repository with nested go.mod files or a go.work
+// workspace. Each package belongs to the module whose directory is the closest parent of the package's.
+type Modules struct {
+	Root    string   // Root of the tree.
+	Import  string   // Import path of the root directory.
+	Repo    string   // Import path of the repository, Import without a major version suffix.
+	Modules []Module // Modules sorted by directory, the one in the root, if any, first.
+}
+
+// FindModules finds the modules in the directory tree rooted at root: the go.mod files in it, skipping
+// the directories the go tool ignores, and the modules a go.work in root uses. Modules that a go.work
+// uses outside of the tree are left out. The import path of root is imp, or if that is empty the module
+// path from root's go.mod, or else the one implied by a nested module, e.g. example.com/repo for
+// example.com/repo/tools in tools/. It is only used for directories that aren't in a module, the
+// import paths of packages in a module come from its go.mod.
+func FindModules(root, imp string) (*Modules, error) {
+	m := &Modules{Root: root, Import: imp}
+	seen := map[string]bool{}
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if p != root && skipDir(info.Name()) {
+			return filepath.SkipDir
+		}
+		if mod := modulePath(p); mod != "" {
+			rel, _ := filepath.Rel(root, p)
+			rel = filepath.ToSlash(rel)
+			m.Modules = append(m.Modules, Module{Path: mod, Dir: rel})
+			seen[rel] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range workspaceDirs(root) {
+		rel := path.Clean(filepath.ToSlash(dir))
+		if seen[rel] || rel == ".." || strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+			continue
+		}
+		if mod := modulePath(filepath.Join(root, filepath.FromSlash(rel))); mod != "" {
+			m.Modules = append(m.Modules, Module{Path: mod, Dir: rel})
+			seen[rel] = true
+		}
+	}
+	sort.Slice(m.Modules, func(i, j int) bool {
+		a, b := m.Modules[i].Dir, m.Modules[j].Dir
+		if a == "." || b == "." {
+			return a == "."
+		}
+		return a < b
+	})
+
+	var rootMod string
+	if len(m.Modules) > 0 && m.Modules[0].Dir == "." {
+		rootMod = m.Modules[0].Path
+	}
+	if m.Import == "" {
+		m.Import = rootMod
+	}
+	if m.Import == "" {
+	derive:
+		for _, mod := range m.Modules {
+			for _, p := range []string{mod.Path, stripMajor(mod.Path)} {
+				if strings.HasSuffix(p, "/"+mod.Dir) {
+					m.Import = strings.TrimSuffix(p, "/"+mod.Dir)
+					break derive
+				}
+			}
+		}
+	}
+	m.Repo = m.Import
+	if major := strings.TrimPrefix(rootMod, stripMajor(rootMod)); major != "" {
+		m.Repo = strings.TrimSuffix(m.Import, major)
+	}
+	return m, nil
+}
+
+// Module returns the module the directory rel, relative to the root, belongs to, or nil if it isn't in
+// a module.
+func (m *Modules) Module(rel string) *Module {
+	rel = path.Clean(filepath.ToSlash(rel))
+	var found *Module
+	for i, mod := range m.Modules {
+		if mod.Dir == "." || rel == mod.Dir || strings.HasPrefix(rel, mod.Dir+"/") {
+			if found == nil || len(mod.Dir) > len(found.Dir) || found.Dir == "." {
+				found = &m.Modules[i]
+			}
+		}
+	}
+	return found
+}
+
+// ImportPath returns the import path of the package in the directory rel, relative to the root: the
+// module path joined with the directory in the module, or, outside of a module, the import path of the
+// root joined with rel.
+func (m *Modules) ImportPath(rel string) string {
+	rel = path.Clean(filepath.ToSlash(rel))
+	mod := m.Module(rel)
+	if mod == nil {
+		if m.Import == "" {
+			return ""
+		}
+		return path.Join(m.Import, rel)
+	}
+	if mod.Dir == "." {
+		return path.Join(mod.Path, rel)
+	}
+	return path.Join(mod.Path, strings.TrimPrefix(strings.TrimPrefix(rel, mod.Dir), "/"))
+}
+
+// Configure sets up config for the package in the directory rel, relative to the root: its import path,
+// and the source links, which point into the repository at the directory of the package.
+func (m *Modules) Configure(rel string, config *Config) {
+	rel = path.Clean(filepath.ToSlash(rel))
+	config.Import = m.ImportPath(rel)
+	config.Repo = m.Repo
+	config.SubPackage = ""
+	if rel != "." {
+		config.SubPackage = rel
+	}
+}
+
+// workspaceDirs returns the directories in the use directives of the go.work in root.
+func workspaceDirs(root string) []string {
+	buf, err := os.ReadFile(filepath.Join(root, "go.work"))
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	block := false
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case block && fields[0] == ")":
+			block = false
+			continue
+		case block:
+		case fields[0] == "use" && len(fields) > 1 && fields[1] == "(":
+			block = true
+			continue
+		case fields[0] == "use" && len(fields) > 1:
+			fields = fields[1:]
+		default:
+			continue
+		}
+		dir := fields[0]
+		if unq, err := strconv.Unquote(dir); err == nil {
+			dir = unq
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
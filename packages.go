@@ -0,0 +1,219 @@
+package godoc2md
+
+import (
+	"fmt"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// PackagesError is returned when a directory holds several packages and none of them can be chosen to
+// be documented. Set Config.PackageName to one of them.
+type PackagesError struct {
+	Dir      string
+	Packages map[string][]string // Files of each package, test files are left out.
+}
+
+func (e *PackagesError) Error() string {
+	names := make([]string, 0, len(e.Packages))
+	for name := range e.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = fmt.Sprintf("%s (%s)", name, strings.Join(e.Packages[name], ", "))
+	}
+	return fmt.Sprintf("%s: multiple packages: %s, set the package name to choose one", e.Dir, strings.Join(names, ", "))
+}
+
+// otherFiles returns the Go files in dir, read with ctxt, that don't belong to the package that is
+// documented, and must be hidden from go/build and godoc. The directory is shown, and its name matched,
+// as path. Files that aren't built in ctxt, e.g. because of a //go:build ignore line, are skipped. The
+// package is, in this order, config.PackageName, the only package in dir, the package named after the
+// directory or the last element of config.Import, or the only package that isn't a command. Test files
+// of other packages than the one chosen and its external test package are hidden too. If no package
+// can be chosen a *PackagesError is returned.
+func otherFiles(ctxt build.Context, dir, path string, config *Config) (map[string]bool, error) {
+	readDir, open := ctxt.ReadDir, ctxt.OpenFile
+	if readDir == nil {
+		readDir = ioutil.ReadDir
+	}
+	if open == nil {
+		open = func(p string) (io.ReadCloser, error) { return os.Open(p) }
+	}
+	join := filepath.Join
+	if ctxt.JoinPath != nil {
+		join = ctxt.JoinPath
+	}
+	fis, err := readDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs := map[string][]string{}  // non-test files by package
+	tests := map[string][]string{} // test files by package
+	fset := token.NewFileSet()
+	for _, fi := range fis {
+		name := fi.Name()
+		if fi.IsDir() || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		if ok, err := ctxt.MatchFile(dir, name); err != nil || !ok {
+			continue
+		}
+		r, err := open(join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(fset, name, r, parser.PackageClauseOnly)
+		r.Close()
+		if err != nil {
+			continue // go/build reports this
+		}
+		if strings.HasSuffix(name, "_test.go") {
+			tests[f.Name.Name] = append(tests[f.Name.Name], name)
+			continue
+		}
+		pkgs[f.Name.Name] = append(pkgs[f.Name.Name], name)
+	}
+
+	chosen, err := choosePackage(path, pkgs, config)
+	if err != nil || chosen == "" {
+		return nil, err
+	}
+	hide := map[string]bool{}
+	for name, files := range pkgs {
+		for _, file := range files {
+			hide[file] = name != chosen
+		}
+	}
+	for name, files := range tests {
+		for _, file := range files {
+			hide[file] = name != chosen && name != chosen+"_test"
+		}
+	}
+	return hide, nil
+}
+
+// choosePackage returns the name of the package to document from pkgs, the files of each package in
+// the directory path, see otherFiles. It returns the empty string when there are no packages.
+func choosePackage(path string, pkgs map[string][]string, config *Config) (string, error) {
+	if config.PackageName != "" {
+		if _, ok := pkgs[config.PackageName]; !ok {
+			return "", fmt.Errorf("%s: no package %s", path, config.PackageName)
+		}
+		return config.PackageName, nil
+	}
+	if len(pkgs) <= 1 {
+		for name := range pkgs {
+			return name, nil
+		}
+		return "", nil
+	}
+
+	want := map[string]bool{pkgKey(filepath.Base(path)): true}
+	if config.Import != "" {
+		want[pkgKey(pathpkg.Base(stripMajor(config.Import)))] = true
+	}
+	var named, libs []string
+	for name := range pkgs {
+		if want[pkgKey(name)] {
+			named = append(named, name)
+		}
+		if name != "main" {
+			libs = append(libs, name)
+		}
+	}
+	switch {
+	case len(named) == 1:
+		return named[0], nil
+	case len(libs) == 1:
+		return libs[0], nil
+	}
+	return "", &PackagesError{Dir: path, Packages: pkgs}
+}
+
+// pkgKey returns the key used to match a package name with a directory name or import path element:
+// "go-yaml", "yaml.v3" and "yaml" are all "yaml".
+func pkgKey(s string) string {
+	s = strings.ToLower(s)
+	if i := strings.Index(s, ".v"); i > 0 {
+		s = s[:i]
+	}
+	s = strings.TrimPrefix(s, "go-")
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "-go"), ".go")
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// packageContext returns build.Default with the Go files in dir that don't belong to the package that
+// is documented hidden, see otherFiles.
+func packageContext(dir string, config *Config) (build.Context, error) {
+	ctxt := defaultContext()
+	hide, err := otherFiles(ctxt, dir, dir, config)
+	if err != nil || len(hide) == 0 {
+		return ctxt, err
+	}
+	ctxt.ReadDir = func(p string) ([]os.FileInfo, error) {
+		fis, err := ioutil.ReadDir(p)
+		if err != nil || filepath.Clean(p) != filepath.Clean(dir) {
+			return fis, err
+		}
+		return hideFiles(fis, hide), nil
+	}
+	return ctxt, nil
+}
+
+// selectPackage returns src, the package directory path, with the Go files that don't belong to the
+// package that is documented hidden, see otherFiles. It must be called with build.Default set up for
+// the documentation, and buildMu held.
+func selectPackage(src vfs.FileSystem, path string, config *Config) (vfs.FileSystem, error) {
+	ctxt := build.Default
+	ctxt.JoinPath = pathpkg.Join
+	ctxt.ReadDir = src.ReadDir
+	ctxt.OpenFile = func(p string) (io.ReadCloser, error) { return src.Open(p) }
+	hide, err := otherFiles(ctxt, "/", path, config)
+	if err != nil || len(hide) == 0 {
+		return src, err
+	}
+	return packageFS{FileSystem: src, hide: hide}, nil
+}
+
+// packageFS is a file system that hides the Go files in its root that belong to other packages.
+type packageFS struct {
+	vfs.FileSystem
+	hide map[string]bool
+}
+
+func (p packageFS) ReadDir(path string) ([]os.FileInfo, error) {
+	fis, err := p.FileSystem.ReadDir(path)
+	if err != nil || pathpkg.Clean(path) != "/" {
+		return fis, err
+	}
+	return hideFiles(fis, p.hide), nil
+}
+
+// hideFiles returns the files in fis that are not hidden.
+func hideFiles(fis []os.FileInfo, hide map[string]bool) []os.FileInfo {
+	var out []os.FileInfo
+	for _, fi := range fis {
+		if !hide[fi.Name()] {
+			out = append(out, fi)
+		}
+	}
+	return out
+}
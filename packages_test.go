@@ -0,0 +1,65 @@
+package godoc2md
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPackageName(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "foo")
+	files := map[string]string{
+		"foo.go":        "// Package foo is the package.\npackage foo\n\n// F is a function.\nfunc F() {}\n",
+		"gen.go":        "//go:build ignore\n\n// Gen is ignored.\npackage main\n\nfunc main() {}\n",
+		"tool.go":       "// Tool is a stray command.\npackage main\n\nfunc main() {}\n",
+		"foo_test.go":   "package foo_test\n\nimport \"example.com/foo\"\n\nfunc ExampleF() {\n\tfoo.F()\n}\n",
+		"other_test.go": "package other\n\nfunc ExampleG() {}\n",
+	}
+	writeFiles(t, dir, files)
+
+	buf := &bytes.Buffer{}
+	if err := Transform(buf, dir, &Config{Import: "example.com/foo"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"# foo\n", "Package foo is the package.", "#### Example {#example_F}", "foo.F()"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q in output, got:\n%s", s, buf.String())
+		}
+	}
+	for _, s := range []string{"Tool", "Gen", "ExampleG"} {
+		if strings.Contains(buf.String(), s) {
+			t.Errorf("unexpected %q in output, got:\n%s", s, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := Transform(buf, dir, &Config{Import: "example.com/foo", PackageName: "main"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Tool is a stray command.") {
+		t.Errorf("expected the main package, got:\n%s", buf.String())
+	}
+
+	// a and b are equally likely
+	if err := os.Remove(filepath.Join(dir, "tool.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "foo.go"), []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.go"), []byte("package b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := Transform(buf, dir, &Config{Import: "example.com/foo"})
+	perr, ok := err.(*PackagesError)
+	if !ok {
+		t.Fatalf("expected a *PackagesError, got %v", err)
+	}
+	if diff := cmp.Diff(map[string][]string{"a": {"foo.go"}, "b": {"b.go"}}, perr.Packages); diff != "" {
+		t.Errorf("unexpected packages (-want +got):\n%s", diff)
+	}
+}
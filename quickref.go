@@ -0,0 +1,103 @@
+package godoc2md
+
+import (
+	"bytes"
+	"errors"
+	"go/doc"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/godoc"
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// writeQuickRef writes the quick reference of the package in info to w, see Config.QuickRef. The
+// Index of refTemplate links to the source instead of the sections of the full documentation, and
+// gives each symbol the anchor its section has there, so links to it keep working. If
+// the output is larger than config.QuickRefBudget, the synopses are truncated to the longest length
+// that fits; the signatures are always kept, so the budget may still be exceeded.
+func writeQuickRef(w io.Writer, ns vfs.NameSpace, pres *godoc.Presentation, info *godoc.PageInfo, config *Config) error {
+	if r, err := config.renderer(); err != nil || r != nil {
+		if err == nil {
+			err = errors.New("the quick reference is only written in markdown")
+		}
+		return err
+	}
+	tmpl, err := readTemplate(pres, ns, "quickref.txt", refTemplate, config, info)
+	if err != nil {
+		return err
+	}
+	a, err := config.anchor()
+	if err != nil {
+		return err
+	}
+	posLink := pres.FuncMap()["posLink_url"].(func(*godoc.PageInfo, interface{}) string)
+	limit, longest := -1, 0
+	tmpl.Funcs(map[string]interface{}{
+		"index_url": func(info *godoc.PageInfo, decl interface{}, id string) string {
+			return posLink(info, decl)
+		},
+		"index_anchor": func(id, heading string) string {
+			if a.Explicit {
+				return `<a id="` + a.Slug(id) + `"></a>`
+			}
+			return `<a id="` + a.Slug(headingText(heading)) + `"></a>`
+		},
+		"synopsis_md": func(text string) string {
+			s := doc.Synopsis(text)
+			if n := utf8.RuneCountInString(s); n > longest {
+				longest = n
+			}
+			return escapeMd(truncate(s, limit), ctxPara)
+		},
+	})
+
+	render := func() ([]byte, error) {
+		buf := &bytes.Buffer{}
+		err := write(buf, tmpl, info, config)
+		return buf.Bytes(), err
+	}
+	out, err := render()
+	if err != nil {
+		return err
+	}
+	if budget := config.QuickRefBudget; budget > 0 && len(out) > budget {
+		// The output only grows with the limit, find the largest one that fits.
+		lo, hi := 0, longest
+		for lo < hi {
+			limit = (lo + hi + 1) / 2
+			if out, err = render(); err != nil {
+				return err
+			}
+			if len(out) <= budget {
+				lo = limit
+			} else {
+				hi = limit - 1
+			}
+		}
+		limit = lo
+		if out, err = render(); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// truncate returns s cut to at most n runes, at a word boundary if there is one, and followed by an
+// ellipsis. A negative n leaves s alone.
+func truncate(s string, n int) string {
+	if n < 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	if n == 0 {
+		return ""
+	}
+	cut := s[:len(string([]rune(s)[:n]))]
+	if i := strings.LastIndexFunc(cut, unicode.IsSpace); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRightFunc(cut, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsPunct(r) }) + "…"
+}
@@ -0,0 +1,116 @@
+package godoc2md
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQuickRef(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "foo")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	src := `// Package foo is the package.
+package foo
+
+// Max is the maximum number of things a client does in a single call to Do.
+const Max = 1
+
+// Client is a client. It has a long comment.
+type Client struct{}
+
+// New returns a new client that does at most Max things.
+func New() *Client { return nil }
+
+// Do does it.
+func (c *Client) Do() {}
+`
+	if err := os.WriteFile(filepath.Join(dir, "foo.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{Import: "example.com/foo", SrcLinkHashFormat: "#L%d", QuickRef: true}
+	buf := &bytes.Buffer{}
+	if err := Transform(buf, dir, config); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{
+		"# foo\n",
+		"Package foo is the package.\n",
+		`* <a id="Client"></a>[type Client](https://example.com/foo/blob/master`,
+		") - Client is a client.\n",
+		`  * <a id="New"></a>[func New() \*Client](https://example.com/foo/blob/master`,
+		`  * <a id="Client.Do"></a>[func (c \*Client) Do()](`,
+		") - New returns a new client that does at most Max things.\n",
+		") - Do does it.\n",
+		"## Constants {#pkg-constants}\n\n* [const Max](",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected %q in output, got:\n%s", s, out)
+		}
+	}
+	for _, s := range []string{"## Overview", "It has a long comment", "type Client struct"} {
+		if strings.Contains(out, s) {
+			t.Errorf("didn't expect %q in output, got:\n%s", s, out)
+		}
+	}
+
+	config.QuickRefBudget = len(out) - 20
+	buf.Reset()
+	if err := Transform(buf, dir, config); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > config.QuickRefBudget {
+		t.Errorf("expected at most %d bytes, got %d:\n%s", config.QuickRefBudget, buf.Len(), buf)
+	}
+	if s := "in a single call to Do."; strings.Contains(buf.String(), s) {
+		t.Errorf("expected the longest synopsis to be truncated, got:\n%s", buf)
+	}
+	if s := ") - Do does it.\n"; !strings.Contains(buf.String(), s) {
+		t.Errorf("expected %q in output, got:\n%s", s, buf)
+	}
+
+	// The anchors are those of the sections in the full documentation.
+	config = &Config{Import: "example.com/foo", Flavor: "github"}
+	buf.Reset()
+	if err := Transform(buf, dir, config); err != nil {
+		t.Fatal(err)
+	}
+	full := buf.String()
+	config.QuickRef = true
+	buf.Reset()
+	if err := Transform(buf, dir, config); err != nil {
+		t.Fatal(err)
+	}
+	for _, anchor := range []string{"type-client", "func-new", "func-client-do"} {
+		if s := `<a id="` + anchor + `"></a>`; !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q in output, got:\n%s", s, buf)
+		}
+		if s := "(#" + anchor + ")"; !strings.Contains(full, s) {
+			t.Errorf("expected %q in full output, got:\n%s", s, full)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s   string
+		n   int
+		exp string
+	}{
+		{"Do does it.", -1, "Do does it."},
+		{"Do does it.", 11, "Do does it."},
+		{"Do does it.", 9, "Do does…"},
+		{"Do does it.", 0, ""},
+		{"Élan, vital", 6, "Élan…"},
+	}
+	for _, tc := range tests {
+		if got := truncate(tc.s, tc.n); got != tc.exp {
+			t.Errorf("truncate(%q, %d): expected %q, got %q", tc.s, tc.n, tc.exp, got)
+		}
+	}
+}
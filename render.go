@@ -0,0 +1,493 @@
+package godoc2md
+
+import (
+	"bytes"
+	"fmt"
+	"go/doc"
+	"go/printer"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/godoc"
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// Span is a piece of inline text: plain text, or a link if URL is set. Links to the documentation
+// itself have an URL of the form "#id", with id the id given to the heading.
+type Span struct {
+	Text string
+	URL  string
+}
+
+// Item is an item of a list, nested lists have a Level above 1.
+type Item struct {
+	Level int
+	Spans []Span
+}
+
+// Page describes the package or command that is documented, for the title or header of a document.
+type Page struct {
+	Name       string // Package name, or the name of the command.
+	ImportPath string
+	Synopsis   string // First sentence of the package documentation.
+	Command    bool   // The page documents a command, e.g. a man page then goes in section 1 instead of 3.
+}
+
+// Renderer writes documentation in an output format other than markdown, see Formats. Comments are
+// parsed once, into headings, paragraphs and code blocks with their URLs and doc links resolved, so
+// these behave the same in every format; the renderer only has to write them. Levels of headings start
+// at 2, level 1 is the title of the page that Begin writes.
+type Renderer interface {
+	Begin(w io.Writer, p Page) // Begin writes the start of the document, e.g. the HTML head or the man page header.
+	End(w io.Writer)
+	Heading(w io.Writer, level int, spans []Span, id string)
+	Paragraph(w io.Writer, spans []Span)
+	Code(w io.Writer, lang, code string) // Code writes a code block, lang is as given by Config.CodeLang.
+	List(w io.Writer, items []Item)
+	Table(w io.Writer, header []string, rows [][]string)
+}
+
+// Formats holds the output formats besides markdown. Config.Format selects one; the empty string and
+// "markdown" use the markdown template. Callers may register their own format in an init function, the
+// map is read without locking and must not change once documentation is being generated.
+var Formats = map[string]func() Renderer{
+	"html":     func() Renderer { return htmlRenderer{} },
+	"asciidoc": func() Renderer { return asciidocRenderer{} },
+	"rst":      func() Renderer { return rstRenderer{} },
+	"man":      func() Renderer { return roffRenderer{} },
+}
+
+// renderer returns the renderer of the format configured in c, or nil for markdown.
+func (c *Config) renderer() (Renderer, error) {
+	if c.Format == "" || c.Format == "markdown" {
+		return nil, nil
+	}
+	f, ok := Formats[c.Format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format: %q", c.Format)
+	}
+	return f(), nil
+}
+
+// writePackage writes the documentation in info to w, as markdown or in the format of config, or the
+// quick reference if config.QuickRef is set.
+func writePackage(w io.Writer, ns vfs.NameSpace, pres *godoc.Presentation, info *godoc.PageInfo, config *Config) error {
+	if config.QuickRef {
+		return writeQuickRef(w, ns, pres, info, config)
+	}
+	r, err := config.renderer()
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		tmpl, err := readTemplate(pres, ns, "package.txt", pkgTemplate, config, info)
+		if err != nil {
+			return err
+		}
+		return write(w, tmpl, info, config)
+	}
+	p := &pageRenderer{r: r, ns: ns, pres: pres, info: info, config: config, links: newDocLinks(info)}
+	buf := &bytes.Buffer{}
+	if err := p.render(buf); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// inline returns the link that starts at byte i of line, and its length in line, or 0 if there is none:
+// one of the URLs in urls, the matches of the URL regexp, or a doc link that links resolves.
+func inline(line string, i int, urls [][]int, links *docLinks) (Span, int) {
+	for _, m := range urls {
+		if m[0] == i {
+			if url := trimURL(line[m[0]:m[1]]); url != "" {
+				return Span{Text: url, URL: url}, len(url)
+			}
+		}
+	}
+	if line[i] != '[' {
+		return Span{}, 0
+	}
+	if m := docLinkRx.FindString(line[i:]); m != "" && !linkFollows(line[i+len(m):]) {
+		name := m[1 : len(m)-1]
+		if url := links.url(name); url != "" {
+			return Span{Text: name, URL: url}, len(m)
+		}
+	}
+	return Span{}, 0
+}
+
+// spans splits text into plain text and links, see inline.
+func spans(text string, urlRx *regexp.Regexp, links *docLinks) []Span {
+	var urls [][]int
+	if urlRx != nil {
+		urls = urlRx.FindAllStringIndex(text, -1)
+	}
+	var out []Span
+	start := 0
+	for i := 0; i < len(text); {
+		s, n := inline(text, i, urls, links)
+		if n == 0 {
+			i++
+			continue
+		}
+		if start < i {
+			out = append(out, Span{Text: text[start:i]})
+		}
+		out = append(out, s)
+		i += n
+		start = i
+	}
+	if start < len(text) {
+		out = append(out, Span{Text: text[start:]})
+	}
+	return out
+}
+
+// renderComment renders the comment text with r: the same blocks that toMd turns into markdown. The
+// lines of a paragraph are joined with spaces.
+func renderComment(r Renderer, w io.Writer, text string, level int, config *Config, links *docLinks) {
+	urlRx := config.urlRegexp()
+	var toc []Item
+	for _, b := range blocks(text) {
+		if b.op == opHead && config.toc(1) {
+			toc = append(toc, Item{Level: 1, Spans: []Span{{Text: b.lines[0], URL: "#" + headingKey(b.lines[0])}}})
+		}
+	}
+	if len(toc) > 0 {
+		r.List(w, toc)
+	}
+
+	for _, b := range blocks(text) {
+		switch b.op {
+		case opPara:
+			para := strings.TrimSpace(strings.Join(b.lines, ""))
+			r.Paragraph(w, spans(strings.Replace(para, "\n", " ", -1), urlRx, links))
+		case opHead:
+			r.Heading(w, level, []Span{{Text: b.lines[0]}}, headingKey(b.lines[0]))
+		case opPre:
+			r.Code(w, config.codeLang(b.lines), strings.TrimRight(strings.Join(b.lines, ""), "\n"))
+		}
+	}
+}
+
+// pageRenderer renders the documentation of a package with a Renderer, it follows pkgTemplate.
+type pageRenderer struct {
+	r      Renderer
+	ns     vfs.NameSpace
+	pres   *godoc.Presentation
+	info   *godoc.PageInfo
+	config *Config
+	links  *docLinks
+
+	since     map[string]string
+	platforms map[string][]string
+}
+
+// heading writes a heading, shifted by Config.HeadingOffset.
+func (p *pageRenderer) heading(w io.Writer, level int, spans []Span, id string) {
+	if level += p.config.HeadingOffset; level > 6 {
+		level = 6
+	}
+	p.r.Heading(w, level, spans, id)
+}
+
+func (p *pageRenderer) comment(w io.Writer, text string) {
+	if text == "" {
+		return
+	}
+	level := 3 + p.config.HeadingOffset
+	if level > 6 {
+		level = 6
+	}
+	renderComment(p.r, w, text, level, p.config, p.links)
+}
+
+// node returns the source of the declaration n.
+func (p *pageRenderer) node(n interface{}) string {
+	buf := &bytes.Buffer{}
+	(&printer.Config{Mode: printer.UseSpaces, Tabwidth: 4}).Fprint(buf, p.info.FSet, n)
+	return buf.String()
+}
+
+// text calls the godoc template function name, e.g. noteTitle, on s.
+func (p *pageRenderer) text(name, s string) string {
+	if f, ok := p.pres.FuncMap()[name].(func(string) string); ok {
+		return f(s)
+	}
+	return s
+}
+
+// posLink returns the URL of the source of n.
+func (p *pageRenderer) posLink(n interface{}) string {
+	if f, ok := p.pres.FuncMap()["posLink_url"].(func(*godoc.PageInfo, interface{}) string); ok {
+		return f(p.info, n)
+	}
+	return ""
+}
+
+// annotation returns the release the symbol id was added in and the build contexts it is available in,
+// as text to put after its name.
+func (p *pageRenderer) annotation(id string) string {
+	s := ""
+	if v := p.since[id]; v != "" {
+		s += " (added in " + v + ")"
+	}
+	if ctxts := p.platforms[id]; len(ctxts) > 0 {
+		s += " (" + strings.Join(ctxts, ", ") + " only)"
+	}
+	return s
+}
+
+// title returns the spans of the heading of the declaration: its kind, its name linking to the source
+// and its annotation.
+func (p *pageRenderer) title(prefix, name, id string, decl interface{}) []Span {
+	spans := []Span{{Text: prefix}, {Text: name, URL: p.posLink(decl)}}
+	if a := p.annotation(id); a != "" {
+		spans = append(spans, Span{Text: a})
+	}
+	return spans
+}
+
+func (p *pageRenderer) examples(w io.Writer, name string) error {
+	for _, eg := range p.info.Examples {
+		sym, suffix := splitExample(eg.Name)
+		if sym != name {
+			continue
+		}
+		title := "Example"
+		if suffix != "" {
+			title += " (" + strings.ToUpper(suffix[:1]) + suffix[1:] + ")"
+		}
+		p.heading(w, 4, []Span{{Text: title}}, "example_"+eg.Name)
+		p.comment(w, eg.Doc)
+		code, err := exampleCode(p.info, eg)
+		if err != nil {
+			return err
+		}
+		p.r.Paragraph(w, []Span{{Text: "Code:"}})
+		p.r.Code(w, "go", code)
+		if eg.Output == "" {
+			continue
+		}
+		label := "Output:"
+		if eg.Unordered {
+			label = "Unordered output:"
+		}
+		p.r.Paragraph(w, []Span{{Text: label}})
+		p.r.Code(w, "text", strings.TrimSuffix(eg.Output, "\n"))
+	}
+	return nil
+}
+
+func (p *pageRenderer) render(w io.Writer) error {
+	info, config := p.info, p.config
+	if info.PDoc == nil {
+		return nil
+	}
+	pdoc := info.PDoc
+	var err error
+	if config.Since {
+		if p.since, err = Since(info.Dirname, config); err != nil {
+			return err
+		}
+	}
+	if len(config.Matrix) > 0 {
+		p.platforms = availability(info.Dirname, config)
+	}
+
+	page := Page{Name: pdoc.Name, ImportPath: pdoc.ImportPath, Synopsis: doc.Synopsis(pdoc.Doc), Command: info.IsMain}
+	if info.IsMain {
+		page.Name = path.Base(pdoc.ImportPath)
+	}
+	p.r.Begin(w, page)
+	defer p.r.End(w)
+
+	p.heading(w, 2, []Span{{Text: "Overview"}}, "pkg-overview")
+	p.comment(w, pdoc.Doc)
+	if info.IsMain {
+		return p.usage(w)
+	}
+	if err := p.examples(w, ""); err != nil {
+		return err
+	}
+
+	if config.toc(1) {
+		p.heading(w, 2, []Span{{Text: "Index"}}, "pkg-index")
+		p.r.List(w, p.index())
+	}
+	if len(info.Examples) > 0 {
+		p.heading(w, 4, []Span{{Text: "Examples"}}, "pkg-examples")
+		var items []Item
+		for _, eg := range info.Examples {
+			items = append(items, Item{Level: 1, Spans: []Span{{Text: p.text("example_name", eg.Name), URL: "#example_" + eg.Name}}})
+		}
+		p.r.List(w, items)
+	}
+	if len(pdoc.Filenames) > 0 {
+		p.heading(w, 4, []Span{{Text: "Package files"}}, "pkg-files")
+		var files []Span
+		for i, f := range pdoc.Filenames {
+			if i > 0 {
+				files = append(files, Span{Text: " "})
+			}
+			files = append(files, Span{Text: path.Base(f), URL: p.text("srcLink", f)})
+		}
+		p.r.Paragraph(w, files)
+	}
+
+	for _, values := range []struct {
+		title, id string
+		values    []*doc.Value
+	}{{"Constants", "pkg-constants", pdoc.Consts}, {"Variables", "pkg-variables", pdoc.Vars}} {
+		if len(values.values) == 0 {
+			continue
+		}
+		p.heading(w, 2, []Span{{Text: values.title}}, values.id)
+		p.values(w, values.values)
+	}
+
+	for _, f := range pdoc.Funcs {
+		if err := p.function(w, 2, "func ", f, f.Name, f.Name); err != nil {
+			return err
+		}
+	}
+	for _, t := range pdoc.Types {
+		p.heading(w, 2, p.title("type ", t.Name, t.Name, t.Decl), t.Name)
+		p.r.Code(w, "go", p.node(t.Decl))
+		p.comment(w, t.Doc)
+		p.values(w, t.Consts)
+		p.values(w, t.Vars)
+		if err := p.examples(w, t.Name); err != nil {
+			return err
+		}
+		for _, f := range t.Funcs {
+			if err := p.function(w, 3, "func ", f, f.Name, f.Name); err != nil {
+				return err
+			}
+		}
+		for _, m := range t.Methods {
+			if err := p.function(w, 3, "func ("+m.Recv+") ", m, t.Name+"."+m.Name, t.Name+"_"+m.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	var markers []string
+	for marker := range info.Notes {
+		markers = append(markers, marker)
+	}
+	sort.Strings(markers)
+	for _, marker := range markers {
+		p.heading(w, 2, []Span{{Text: p.text("noteTitle", marker) + "s"}}, "pkg-note-"+marker)
+		var items []Item
+		for _, n := range info.Notes[marker] {
+			items = append(items, Item{Level: 1, Spans: []Span{{Text: strings.TrimSpace(n.Body)}}})
+		}
+		p.r.List(w, items)
+	}
+
+	if info.Dirs != nil {
+		var items []Item
+		for _, d := range info.Dirs.List {
+			if d.HasPkg {
+				items = append(items, Item{Level: 1, Spans: []Span{{Text: path.Join(pdoc.ImportPath, d.Name)}}})
+			}
+		}
+		if len(items) > 0 {
+			p.heading(w, 4, []Span{{Text: "Subdirectories"}}, "pkg-subdirectories")
+			p.r.List(w, items)
+		}
+	}
+	return nil
+}
+
+// function renders the function or method f, id is its heading id and example the name its examples
+// are for.
+func (p *pageRenderer) function(w io.Writer, level int, prefix string, f *doc.Func, id, example string) error {
+	p.heading(w, level, p.title(prefix, f.Name, id, f.Decl), id)
+	p.r.Code(w, "go", p.node(f.Decl))
+	p.comment(w, f.Doc)
+	return p.examples(w, example)
+}
+
+func (p *pageRenderer) values(w io.Writer, values []*doc.Value) {
+	for _, v := range values {
+		p.r.Code(w, "go", p.node(v.Decl))
+		p.comment(w, v.Doc)
+	}
+}
+
+// index returns the items of the index: the constants, variables, functions, types and their
+// functions and methods.
+func (p *pageRenderer) index() []Item {
+	pdoc := p.info.PDoc
+	var items []Item
+	item := func(level int, text, id string) {
+		items = append(items, Item{Level: level, Spans: []Span{{Text: text, URL: "#" + id}, {Text: p.annotation(id)}}})
+	}
+	if len(pdoc.Consts) > 0 {
+		item(1, "Constants", "pkg-constants")
+	}
+	if len(pdoc.Vars) > 0 {
+		item(1, "Variables", "pkg-variables")
+	}
+	for _, f := range pdoc.Funcs {
+		item(1, p.node(f.Decl), f.Name)
+	}
+	for _, t := range pdoc.Types {
+		item(1, "type "+t.Name, t.Name)
+		if !p.config.toc(2) {
+			continue
+		}
+		for _, f := range t.Funcs {
+			item(2, p.node(f.Decl), f.Name)
+		}
+		for _, m := range t.Methods {
+			item(2, p.node(m.Decl), t.Name+"."+m.Name)
+		}
+	}
+	for i := range items {
+		if items[i].Spans[1].Text == "" {
+			items[i].Spans = items[i].Spans[:1]
+		}
+	}
+	return items
+}
+
+// usage renders the usage text and flags of a command and its subcommands.
+func (p *pageRenderer) usage(w io.Writer) error {
+	cmd, err := parseCommand(p.ns, p.info.Dirname)
+	if err != nil {
+		return err
+	}
+	if len(cmd.Flags) == 0 && cmd.Usage == "" && len(cmd.Subcommands) == 0 {
+		return nil
+	}
+	p.heading(w, 2, []Span{{Text: "Usage"}}, "pkg-usage")
+	p.command(w, cmd)
+	for _, sub := range cmd.Subcommands {
+		if len(sub.Flags) > 0 || sub.Usage != "" {
+			p.heading(w, 3, []Span{{Text: sub.Name}}, "pkg-usage-"+strings.TrimPrefix(headingKey(sub.Name), "hdr-"))
+			p.command(w, sub)
+		}
+	}
+	return nil
+}
+
+func (p *pageRenderer) command(w io.Writer, c *Command) {
+	if c.Usage != "" {
+		p.r.Code(w, "", c.Usage)
+	}
+	if len(c.Flags) == 0 {
+		return
+	}
+	var rows [][]string
+	for _, f := range c.Flags {
+		rows = append(rows, []string{f.flag(), f.Type, f.Default, f.Usage})
+	}
+	p.r.Table(w, []string{"Flag", "Type", "Default", "Description"}, rows)
+}
@@ -0,0 +1,64 @@
+package godoc2md
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormats(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "foo")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	src := "// Package foo is the package.\n//\n// Details\n//\n// See [F] and https://example.org.\n//\n//\tfoo.F()\npackage foo\n\n// F is a function.\nfunc F() {}\n"
+	if err := os.WriteFile(filepath.Join(dir, "foo.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]string{
+		"html": {
+			"<title>package foo</title>",
+			`<h3 id="hdr-Details">Details</h3>`,
+			`See <a href="#F">F</a> and <a href="https://example.org">https://example.org</a>.`,
+			`<pre><code class="language-go">foo.F()</code></pre>`,
+		},
+		"asciidoc": {
+			"= package foo\n",
+			"[[hdr-Details]]\n=== Details\n",
+			"See <<F,F>> and https://example.org.",
+			"[source,go]\n----\nfoo.F()\n----\n",
+		},
+		"rst": {
+			"===========\npackage foo\n===========\n",
+			".. _hdr-Details:\n\nDetails\n-------\n",
+			"See `F <F_>`__ and https://example.org.",
+			".. code-block:: go\n\n   foo.F()\n",
+		},
+		"man": {
+			`.TH "FOO" 3`,
+			"foo \\- Package foo is the package.",
+			".SH DESCRIPTION\n",
+			".SS \"Details\"\n",
+			"See F and https://example.org.",
+			".nf\nfoo.F()\n.fi\n",
+		},
+	}
+	for format, exp := range tests {
+		buf := &bytes.Buffer{}
+		if err := Transform(buf, dir, &Config{Import: "example.com/foo", Format: format}); err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range exp {
+			if !strings.Contains(buf.String(), s) {
+				t.Errorf("%s: expected %q in output, got:\n%s", format, s, buf.String())
+			}
+		}
+	}
+
+	if err := Transform(&bytes.Buffer{}, dir, &Config{Import: "example.com/foo", Format: "pdf"}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
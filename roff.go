@@ -0,0 +1,126 @@
+package godoc2md
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// roffRenderer renders a man page: section 3 for packages and section 1 for commands.
+type roffRenderer struct{}
+
+func (roffRenderer) Begin(w io.Writer, p Page) {
+	section := "3"
+	if p.Command {
+		section = "1"
+	}
+	fmt.Fprintf(w, ".TH %s %s\n", roffQuote(roffText(strings.ToUpper(p.Name))), section)
+	io.WriteString(w, ".SH NAME\n")
+	if p.Synopsis != "" {
+		fmt.Fprintf(w, "%s \\- %s\n", roffText(p.Name), roffText(p.Synopsis))
+	} else {
+		fmt.Fprintf(w, "%s\n", roffText(p.Name))
+	}
+	io.WriteString(w, ".SH SYNOPSIS\n")
+	if p.Command {
+		fmt.Fprintf(w, ".B %s\n", roffText(p.Name))
+		return
+	}
+	fmt.Fprintf(w, ".B %s\n", roffQuote(roffText(fmt.Sprintf("import %q", p.ImportPath))))
+}
+
+func (roffRenderer) End(w io.Writer) {}
+
+// Heading writes a section for level 2 headings, and a subsection for the others. The titles of the
+// package's sections are in upper case, with the Overview as the DESCRIPTION; links are left out.
+func (roffRenderer) Heading(w io.Writer, level int, spans []Span, id string) {
+	text := make([]Span, len(spans))
+	for i, s := range spans {
+		text[i] = Span{Text: s.Text}
+		if level == 2 && strings.HasPrefix(id, "pkg-") {
+			text[i].Text = strings.ToUpper(s.Text)
+		}
+	}
+	switch {
+	case level > 2:
+		fmt.Fprintf(w, ".SS %s\n", roffQuote(roffSpans(text)))
+	case id == "pkg-overview":
+		io.WriteString(w, ".SH DESCRIPTION\n")
+	default:
+		fmt.Fprintf(w, ".SH %s\n", roffQuote(roffSpans(text)))
+	}
+}
+
+func (roffRenderer) Paragraph(w io.Writer, spans []Span) {
+	fmt.Fprintf(w, ".PP\n%s\n", roffLine(roffSpans(spans)))
+}
+
+func (roffRenderer) Code(w io.Writer, lang, code string) {
+	io.WriteString(w, ".PP\n.RS\n.nf\n")
+	for _, line := range strings.Split(code, "\n") {
+		fmt.Fprintf(w, "%s\n", roffLine(roffText(line)))
+	}
+	io.WriteString(w, ".fi\n.RE\n")
+}
+
+func (roffRenderer) List(w io.Writer, items []Item) {
+	for _, it := range items {
+		if it.Level > 1 {
+			fmt.Fprintf(w, ".RS %d\n", 2*(it.Level-1))
+		}
+		fmt.Fprintf(w, ".IP \\(bu 2\n%s\n", roffLine(roffSpans(it.Spans)))
+		if it.Level > 1 {
+			io.WriteString(w, ".RE\n")
+		}
+	}
+}
+
+// Table writes a tagged paragraph for each row, the first cell is the tag and the others, with their
+// header, the text.
+func (roffRenderer) Table(w io.Writer, header []string, rows [][]string) {
+	for _, row := range rows {
+		fmt.Fprintf(w, ".TP\n.B %s\n", roffQuote(roffText(row[0])))
+		var text []string
+		for i, c := range row[1:] {
+			if c == "" {
+				continue
+			}
+			if i+1 == len(row)-1 {
+				text = append(text, roffText(c))
+				continue
+			}
+			text = append(text, roffText(header[i+1]+": "+c))
+		}
+		fmt.Fprintf(w, "%s\n", roffLine(strings.Join(text, "\n.br\n")))
+	}
+}
+
+// roffSpans returns the spans as roff text, links are written as "text <url>".
+func roffSpans(spans []Span) string {
+	b := &strings.Builder{}
+	for _, s := range spans {
+		switch {
+		case s.URL == "" || strings.HasPrefix(s.URL, "#") || s.URL == s.Text:
+			b.WriteString(roffText(s.Text))
+		default:
+			b.WriteString(roffText(s.Text + " <" + s.URL + ">"))
+		}
+	}
+	return b.String()
+}
+
+// roffText escapes backslashes and hyphens in s.
+var roffText = strings.NewReplacer(`\`, `\e`, "-", `\-`, "\n", " ").Replace
+
+// roffLine keeps a line of text from being read as a request.
+func roffLine(s string) string {
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		return `\&` + s
+	}
+	return s
+}
+
+// roffQuote returns the roff text s as a single argument of a request.
+func roffQuote(s string) string {
+	return `"` + strings.Replace(s, `"`, `\(dq`, -1) + `"`
+}
@@ -0,0 +1,124 @@
+package godoc2md
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// rstRenderer renders a reStructuredText document.
+type rstRenderer struct{}
+
+// rstAdornments are the underlines of the headings of each level, the title is over and underlined.
+var rstAdornments = []string{"", "=", "=", "-", "~", "^", `"`}
+
+func (rstRenderer) Begin(w io.Writer, p Page) {
+	title := p.Name
+	if !p.Command {
+		title = "package " + p.Name
+	}
+	line := strings.Repeat("=", utf8.RuneCountInString(title))
+	fmt.Fprintf(w, "%s\n%s\n%s\n\n", line, rstText(title), line)
+	if p.Synopsis != "" {
+		fmt.Fprintf(w, ".. meta::\n   :description: %s\n\n", strings.Replace(p.Synopsis, "\n", " ", -1))
+	}
+	if !p.Command {
+		fmt.Fprintf(w, "``import %q``\n\n", p.ImportPath)
+	}
+}
+
+func (rstRenderer) End(w io.Writer) {}
+
+func (rstRenderer) Heading(w io.Writer, level int, spans []Span, id string) {
+	text := rstSpans(spans)
+	fmt.Fprintf(w, ".. _%s:\n\n%s\n%s\n\n", id, text, strings.Repeat(rstAdornments[level], utf8.RuneCountInString(text)))
+}
+
+func (rstRenderer) Paragraph(w io.Writer, spans []Span) {
+	fmt.Fprintf(w, "%s\n\n", rstLine(rstSpans(spans)))
+}
+
+func (rstRenderer) Code(w io.Writer, lang, code string) {
+	if lang == "" {
+		io.WriteString(w, "::\n\n")
+	} else {
+		fmt.Fprintf(w, ".. code-block:: %s\n\n", lang)
+	}
+	for _, line := range strings.Split(code, "\n") {
+		if line == "" {
+			io.WriteString(w, "\n")
+			continue
+		}
+		fmt.Fprintf(w, "   %s\n", line)
+	}
+	io.WriteString(w, "\n")
+}
+
+// List writes a bullet list, nested lists must be separated by blank lines.
+func (rstRenderer) List(w io.Writer, items []Item) {
+	for i, it := range items {
+		if i > 0 && items[i-1].Level != it.Level {
+			io.WriteString(w, "\n")
+		}
+		fmt.Fprintf(w, "%s* %s\n", strings.Repeat("  ", it.Level-1), rstSpans(it.Spans))
+	}
+	io.WriteString(w, "\n")
+}
+
+func (rstRenderer) Table(w io.Writer, header []string, rows [][]string) {
+	io.WriteString(w, ".. list-table::\n   :header-rows: 1\n\n")
+	for _, row := range append([][]string{header}, rows...) {
+		for i, c := range row {
+			prefix := "     -"
+			if i == 0 {
+				prefix = "   * -"
+			}
+			if c == "" {
+				fmt.Fprintf(w, "%s\n", prefix)
+				continue
+			}
+			fmt.Fprintf(w, "%s %s\n", prefix, rstText(c))
+		}
+	}
+	io.WriteString(w, "\n")
+}
+
+// rstSpans returns the spans as reStructuredText: links are anonymous hyperlink references, links into
+// the document refer to the targets written before the headings.
+func rstSpans(spans []Span) string {
+	b := &strings.Builder{}
+	for _, s := range spans {
+		switch {
+		case s.URL == "":
+			b.WriteString(rstText(s.Text))
+		case s.URL == s.Text:
+			b.WriteString(s.URL)
+		case strings.HasPrefix(s.URL, "#"):
+			fmt.Fprintf(b, "`%s <%s_>`__", rstLinkText(s.Text), s.URL[1:])
+		default:
+			fmt.Fprintf(b, "`%s <%s>`__", rstLinkText(s.Text), s.URL)
+		}
+	}
+	return b.String()
+}
+
+// rstText escapes the inline markup characters in s.
+var rstText = strings.NewReplacer(`\`, `\\`, "*", `\*`, "`", "\\`", "_", `\_`, "|", `\|`, "\n", " ").Replace
+
+// rstLinkText escapes s for the text of a hyperlink reference.
+var rstLinkText = strings.NewReplacer(`\`, `\\`, "`", "\\`", "<", `\<`, "\n", " ").Replace
+
+// rstLine keeps a paragraph from being read as a list, a comment, a field or a section adornment.
+func rstLine(s string) string {
+	if s == "" {
+		return s
+	}
+	if strings.IndexByte("-+*#.:=~^'\"", s[0]) >= 0 {
+		return `\` + s
+	}
+	if i := strings.IndexAny(s, ".)"); i > 0 && i < 4 && strings.Trim(s[:i], "0123456789(") == "" {
+		return `\` + s
+	}
+	return s
+}
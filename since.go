@@ -0,0 +1,203 @@
+package godoc2md
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	semverRx = regexp.MustCompile(`^v([0-9]+)\.([0-9]+)\.([0-9]+)$`)
+	// sinceRx matches the release a heading's symbol was added in, as added by the template.
+	sinceRx = regexp.MustCompile(` \*added in v[0-9]+\.[0-9]+\.[0-9]+\*$`)
+)
+
+// semverLess returns true if the release version a comes before b, both must match semverRx.
+func semverLess(a, b string) bool {
+	ma, mb := semverRx.FindStringSubmatch(a), semverRx.FindStringSubmatch(b)
+	for i := 1; i < 4; i++ {
+		x, _ := strconv.Atoi(ma[i])
+		y, _ := strconv.Atoi(mb[i])
+		if x != y {
+			return x < y
+		}
+	}
+	return false
+}
+
+// tag is a release tag and the commit it points to.
+type tag struct {
+	name, commit string
+}
+
+// releaseTags returns the semver release tags of the repository in root, oldest first. Pre-releases
+// are skipped, like pkg.go.dev does.
+func releaseTags(root string) ([]tag, error) {
+	out, err := exec.Command("git", "-C", root, "for-each-ref", "--format=%(refname:short) %(objectname) %(*objectname)", "refs/tags").Output()
+	if err != nil {
+		return nil, err
+	}
+	var tags []tag
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Fields(line)
+		if len(f) < 2 || !semverRx.MatchString(f[0]) {
+			continue
+		}
+		t := tag{f[0], f[1]}
+		if len(f) > 2 { // annotated tag, use the commit
+			t.commit = f[2]
+		}
+		tags = append(tags, t)
+	}
+	sort.Slice(tags, func(i, j int) bool { return semverLess(tags[i].name, tags[j].name) })
+	return tags, nil
+}
+
+// sinceCacheVersion is the version of the sinceCache format, caches of other versions are rebuilt.
+const sinceCacheVersion = 1
+
+// sinceCache is the on disk cache of the exported symbols of a package at each tag.
+type sinceCache struct {
+	Version int                   `json:"version"`
+	Tags    map[string]sinceEntry `json:"tags"`
+}
+
+type sinceEntry struct {
+	Commit  string   `json:"commit"`
+	Symbols []string `json:"symbols"`
+	Absent  bool     `json:"absent,omitempty"` // There is no package, or none that builds, at the tag.
+}
+
+// cacheFile returns the file in which the symbols of the package in dir rel of repository root are cached.
+func (c *Config) cacheFile(root, rel string) (string, error) {
+	dir := c.SinceCache
+	if dir == "" {
+		ucd, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(ucd, "godoc2md", "since")
+	}
+	sum := sha256.Sum256([]byte(root + "\x00" + rel))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// symbols returns the godoc ids, F, T and T.M, of the exported functions, types and methods of the
+// package in path.
+func symbols(path string, config *Config) ([]string, error) {
+	syms, err := api(path, config)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for name := range syms {
+		for _, prefix := range []string{"func ", "type ", "method "} {
+			if strings.HasPrefix(name, prefix) {
+				ids = append(ids, strings.TrimPrefix(name, prefix))
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Since returns for each exported function, type and method of the package in dir, the first release
+// tag of the git repository it appeared in. Like pkg.go.dev, symbols that are in the first release of
+// the package are left out, as are symbols that aren't released yet. The symbols are keyed by their
+// godoc id: F, T or T.M. The symbols at each tag are cached on disk, so only new tags are processed.
+// If a tag can't be checked out the error is returned and the tag is tried again the next time.
+func Since(dir string, config *Config) (map[string]string, error) {
+	root, rel, err := gitRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := releaseTags(root)
+	if err != nil {
+		return nil, err
+	}
+	file, err := config.cacheFile(root, rel)
+	if err != nil {
+		return nil, err
+	}
+	cache := &sinceCache{}
+	if buf, err := os.ReadFile(file); err == nil {
+		json.Unmarshal(buf, cache) // a corrupt cache is rebuilt
+	}
+	if cache.Version != sinceCacheVersion || cache.Tags == nil {
+		cache = &sinceCache{Version: sinceCacheVersion, Tags: map[string]sinceEntry{}}
+	}
+
+	since := map[string]string{}
+	dirty := false
+	first := true // first release that has the package
+	for _, t := range tags {
+		e, ok := cache.Tags[t.name]
+		if !ok || e.Commit != t.commit {
+			if e, err = tagSymbols(root, rel, dir, t, config); err != nil {
+				return nil, fmt.Errorf("%s: %s", t.name, err)
+			}
+			cache.Tags[t.name] = e
+			dirty = true
+		}
+		for _, s := range e.Symbols {
+			if _, ok := since[s]; !ok {
+				since[s] = t.name
+				if first {
+					since[s] = ""
+				}
+			}
+		}
+		first = first && e.Absent
+	}
+	for s, v := range since {
+		if v == "" {
+			delete(since, s)
+		}
+	}
+
+	if dirty {
+		buf, err := json.Marshal(cache)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(file, buf, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return since, nil
+}
+
+// tagSymbols returns the cache entry of the package in dir, the directory rel of the repository root, at
+// the tag t. An error, of git or the file system, may be transient, so nothing must be cached for the
+// tag; a package that doesn't exist or doesn't build at the tag is marked Absent instead.
+func tagSymbols(root, rel, dir string, t tag, config *Config) (sinceEntry, error) {
+	e := sinceEntry{Commit: t.commit}
+	ok, err := gitHasPath(root, t.commit, rel)
+	if err != nil {
+		return e, err
+	}
+	if !ok {
+		e.Absent = true
+		return e, nil
+	}
+	p, cleanup, err := Checkout(t.commit, dir)
+	if err != nil {
+		return e, err
+	}
+	defer cleanup()
+	if e.Symbols, err = symbols(p, config); err != nil {
+		e.Absent = true
+	}
+	return e, nil
+}
@@ -0,0 +1,332 @@
+package godoc2md
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/build"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SiteOptions configures the site written by Site.
+type SiteOptions struct {
+	Generator   string // Static site generator, see Generators.
+	FrontMatter string // Front matter format, "yaml" or "toml", defaults to "yaml".
+	// Module version put in the front matter, defaults to the latest release tag of the git repository
+	// the tree is in, if any.
+	Version string
+}
+
+// SitePage is a page of the site written by Site: a package, or a directory on the way to packages,
+// which only has a page if the generator needs one for its navigation.
+type SitePage struct {
+	Dir        string      // Directory relative to the root, slash separated, "." for the root.
+	File       string      // File of the page relative to the output directory, empty if Dir has no package.
+	Title      string      // Package name, name of the command, or name of the directory.
+	Weight     int         // Position among the pages in the same directory, starting at 1.
+	ImportPath string      // Import path of the package.
+	Synopsis   string      // First sentence of the package documentation.
+	Version    string      // Version of the module, see SiteOptions.
+	Children   []*SitePage // Pages of the subdirectories, sorted by directory.
+}
+
+// Generator describes how a static site generator lays out pages and navigation.
+type Generator struct {
+	TOML   bool                    // TOML front matter is understood, besides YAML.
+	Weight string                  // Front matter key of the page weight, which orders the navigation.
+	Page   func(dir string) string // File of the page of the package in the directory dir.
+	// Nav returns the navigation files, by name relative to the output directory, for the tree of
+	// pages rooted at root. frontMatter returns the front matter of a page.
+	Nav func(root *SitePage, frontMatter func(*SitePage) []byte) (map[string][]byte, error)
+}
+
+// Generators holds all known static site generators. A caller adding one should do so before calling
+// Site, e.g. from init, as Site reads the map unsynchronized.
+var Generators = map[string]*Generator{
+	"hugo":       {TOML: true, Weight: "weight", Page: sectionPage("_index.md"), Nav: hugoNav},
+	"mkdocs":     {Weight: "weight", Page: sectionPage("index.md"), Nav: mkdocsNav},
+	"docusaurus": {Weight: "sidebar_position", Page: sectionPage("index.md"), Nav: docusaurusNav},
+}
+
+// sectionPage returns a Page function that puts the page of a package in the file name in its directory.
+func sectionPage(name string) func(string) string {
+	return func(dir string) string { return path.Join(dir, name) }
+}
+
+// Site writes the documentation of all packages in the directory tree rooted at root as a site for a
+// static site generator to the directory out: a page with front matter for each package, in the same
+// directory structure, and the generator's navigation, which mirrors the package tree. The import paths
+// come from the go.mod files in the tree, or config.Import, as with ModuleIndex.
+func Site(root, out string, opts SiteOptions, config *Config) error {
+	gen, ok := Generators[opts.Generator]
+	if !ok {
+		return fmt.Errorf("unknown site generator: %q", opts.Generator)
+	}
+	if r, err := config.renderer(); err != nil || r != nil {
+		if err == nil {
+			err = errors.New("a site is only written in markdown")
+		}
+		return err
+	}
+	switch opts.FrontMatter {
+	case "", "yaml":
+	case "toml":
+		if !gen.TOML {
+			return fmt.Errorf("%s doesn't support TOML front matter", opts.Generator)
+		}
+	default:
+		return fmt.Errorf("unknown front matter format: %q", opts.FrontMatter)
+	}
+	mods, err := FindModules(root, config.Import)
+	if err != nil {
+		return err
+	}
+	if mods.Import == "" && len(mods.Modules) == 0 {
+		return fmt.Errorf("%s: no import path and no go.mod", root)
+	}
+	version := opts.Version
+	if version == "" {
+		if tags, _ := releaseTags(root); len(tags) > 0 {
+			version = tags[len(tags)-1].name
+		}
+	}
+
+	pages := map[string]*SitePage{}
+	docs := map[string][]byte{}
+	err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if p != root && skipDir(info.Name()) {
+			return filepath.SkipDir
+		}
+		ctxt, err := packageContext(p, config)
+		if _, ok := err.(*PackagesError); ok {
+			return err
+		}
+		if err != nil {
+			return nil
+		}
+		pkg, err := ctxt.ImportDir(p, build.ImportComment)
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+
+		c := *config
+		mods.Configure(rel, &c)
+		buf := &bytes.Buffer{}
+		if err := Transform(buf, p, &c); err != nil {
+			return fmt.Errorf("%s: %s", p, err)
+		}
+		page := &SitePage{Dir: rel, File: gen.Page(rel), Title: pkg.Name, ImportPath: c.Import, Synopsis: pkg.Doc, Version: version}
+		if pkg.Name == "main" {
+			page.Title = path.Base(c.Import)
+		}
+		pages[rel] = page
+		docs[rel] = buf.Bytes()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	tree := siteTree(pages, mods.Import)
+	frontMatter := func(p *SitePage) []byte { return p.frontMatter(opts.FrontMatter, gen.Weight) }
+	files := map[string][]byte{}
+	for rel, page := range pages {
+		files[page.File] = append(frontMatter(page), docs[rel]...)
+	}
+	nav, err := gen.Nav(tree, frontMatter)
+	if err != nil {
+		return err
+	}
+	for name, data := range nav {
+		files[name] = data
+	}
+	for name, data := range files {
+		file := filepath.Join(out, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// siteTree returns the tree of pages, with pages added for the directories that lead to the packages,
+// and the weights set. A root without a package is titled imp.
+func siteTree(pages map[string]*SitePage, imp string) *SitePage {
+	root := pages["."]
+	if root == nil {
+		root = &SitePage{Dir: ".", Title: imp}
+	}
+	root.Weight = 1
+	dirs := map[string]*SitePage{".": root}
+	var add func(dir string) *SitePage
+	add = func(dir string) *SitePage {
+		if p, ok := dirs[dir]; ok {
+			return p
+		}
+		p := pages[dir]
+		if p == nil {
+			p = &SitePage{Dir: dir, Title: path.Base(dir)}
+		}
+		dirs[dir] = p
+		parent := add(path.Dir(dir))
+		parent.Children = append(parent.Children, p)
+		return p
+	}
+	for dir := range pages {
+		add(dir)
+	}
+	for _, p := range dirs {
+		sort.Slice(p.Children, func(i, j int) bool { return p.Children[i].Dir < p.Children[j].Dir })
+		for i, c := range p.Children {
+			c.Weight = i + 1
+		}
+	}
+	return root
+}
+
+// frontMatter returns the front matter of the page in format, "yaml" or "toml", with the weight under
+// the key weight. Empty fields are left out.
+func (p *SitePage) frontMatter(format, weight string) []byte {
+	delim, sep := "---", ": "
+	if format == "toml" {
+		delim, sep = "+++", " = "
+	}
+	b := &bytes.Buffer{}
+	b.WriteString(delim + "\n")
+	field := func(key, value string) {
+		if value != "" {
+			b.WriteString(key + sep + quote(value) + "\n")
+		}
+	}
+	field("title", p.Title)
+	fmt.Fprintf(b, "%s%s%d\n", weight, sep, p.Weight)
+	field("import_path", p.ImportPath)
+	field("description", p.Synopsis)
+	field("version", p.Version)
+	b.WriteString(delim + "\n\n")
+	return b.Bytes()
+}
+
+// quote returns s as a double quoted string, which YAML, TOML and JSON all read the same.
+func quote(s string) string {
+	b := &strings.Builder{}
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// hugoNav returns an _index.md for each directory on the way to packages, so Hugo's sections mirror
+// the package tree. The pages of the packages are sections themselves.
+func hugoNav(root *SitePage, frontMatter func(*SitePage) []byte) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	var walk func(p *SitePage)
+	walk = func(p *SitePage) {
+		if p.File == "" {
+			files[path.Join(p.Dir, "_index.md")] = frontMatter(p)
+		}
+		for _, c := range p.Children {
+			walk(c)
+		}
+	}
+	walk(root)
+	return files, nil
+}
+
+// mkdocsNav returns nav.yml with the nav of the package tree, which mkdocs.yml can INHERIT from. The
+// page of a directory comes first in its section.
+func mkdocsNav(root *SitePage, frontMatter func(*SitePage) []byte) (map[string][]byte, error) {
+	b := &bytes.Buffer{}
+	b.WriteString("nav:\n")
+	var walk func(p *SitePage, indent string)
+	walk = func(p *SitePage, indent string) {
+		if p.File != "" {
+			fmt.Fprintf(b, "%s- %s: %s\n", indent, quote(p.Title), quote(p.File))
+		}
+		for _, c := range p.Children {
+			if len(c.Children) == 0 {
+				walk(c, indent)
+				continue
+			}
+			fmt.Fprintf(b, "%s- %s:\n", indent, quote(c.Title))
+			walk(c, indent+"    ")
+		}
+	}
+	walk(root, "  ")
+	return map[string][]byte{"nav.yml": b.Bytes()}, nil
+}
+
+// docusaurusItem is an item of a Docusaurus sidebar.
+type docusaurusItem struct {
+	Type  string            `json:"type"`
+	ID    string            `json:"id,omitempty"`
+	Label string            `json:"label"`
+	Link  *docusaurusLink   `json:"link,omitempty"`
+	Items []*docusaurusItem `json:"items,omitempty"`
+}
+
+// docusaurusLink is the page a category of a Docusaurus sidebar links to.
+type docusaurusLink struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// docusaurusNav returns sidebars.json with the "api" sidebar of the package tree. Directories are
+// categories, linking to the page of their package, if any. The ids of the pages are relative to the
+// output directory, which should be the docs directory.
+func docusaurusNav(root *SitePage, frontMatter func(*SitePage) []byte) (map[string][]byte, error) {
+	doc := func(p *SitePage) *docusaurusItem {
+		return &docusaurusItem{Type: "doc", ID: strings.TrimSuffix(p.File, path.Ext(p.File)), Label: p.Title}
+	}
+	var items func(p *SitePage) []*docusaurusItem
+	items = func(p *SitePage) []*docusaurusItem {
+		var out []*docusaurusItem
+		for _, c := range p.Children {
+			if len(c.Children) == 0 {
+				out = append(out, doc(c))
+				continue
+			}
+			cat := &docusaurusItem{Type: "category", Label: c.Title, Items: items(c)}
+			if c.File != "" {
+				cat.Link = &docusaurusLink{Type: "doc", ID: doc(c).ID}
+			}
+			out = append(out, cat)
+		}
+		return out
+	}
+	sidebar := items(root)
+	if root.File != "" {
+		sidebar = append([]*docusaurusItem{doc(root)}, sidebar...)
+	}
+	buf, err := json.MarshalIndent(map[string][]*docusaurusItem{"api": sidebar}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{"sidebars.json": append(buf, '\n')}, nil
+}
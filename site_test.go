@@ -0,0 +1,73 @@
+package godoc2md
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSite(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":        "module example.com/foo\n",
+		"foo.go":        "// Package foo is the \"root\" package.\npackage foo\n",
+		"a/b/b.go":      "// Package b is nested.\npackage b\n",
+		"cmd/c/main.go": "// Command c does things.\npackage main\n\nfunc main() {}\n",
+	}
+	writeFiles(t, root, files)
+
+	tests := []struct {
+		opts SiteOptions
+		exp  map[string]string // file: expected prefix
+	}{
+		{
+			SiteOptions{Generator: "hugo", FrontMatter: "toml", Version: "v1.2.0"},
+			map[string]string{
+				"_index.md":       "+++\ntitle = \"foo\"\nweight = 1\nimport_path = \"example.com/foo\"\ndescription = \"Package foo is the \\\"root\\\" package.\"\nversion = \"v1.2.0\"\n+++\n\n# foo\n",
+				"a/_index.md":     "+++\ntitle = \"a\"\nweight = 1\n+++\n",
+				"a/b/_index.md":   "+++\ntitle = \"b\"\nweight = 1\nimport_path = \"example.com/foo/a/b\"\n",
+				"cmd/c/_index.md": "+++\ntitle = \"c\"\nweight = 1\nimport_path = \"example.com/foo/cmd/c\"\ndescription = \"Command c does things.\"\n",
+				"cmd/_index.md":   "+++\ntitle = \"cmd\"\nweight = 2\n+++\n",
+			},
+		},
+		{
+			SiteOptions{Generator: "mkdocs"},
+			map[string]string{
+				"index.md":     "---\ntitle: \"foo\"\nweight: 1\n",
+				"a/b/index.md": "---\ntitle: \"b\"\n",
+				"nav.yml":      "nav:\n  - \"foo\": \"index.md\"\n  - \"a\":\n      - \"b\": \"a/b/index.md\"\n  - \"cmd\":\n      - \"c\": \"cmd/c/index.md\"\n",
+			},
+		},
+		{
+			SiteOptions{Generator: "docusaurus"},
+			map[string]string{
+				"cmd/c/index.md": "---\ntitle: \"c\"\nsidebar_position: 1\n",
+				"sidebars.json":  "{\n  \"api\": [\n    {\n      \"type\": \"doc\",\n      \"id\": \"index\",\n      \"label\": \"foo\"\n    },\n    {\n      \"type\": \"category\",\n      \"label\": \"a\",\n",
+			},
+		},
+	}
+	for _, tc := range tests {
+		out := t.TempDir()
+		if err := Site(root, out, tc.opts, &Config{}); err != nil {
+			t.Fatal(err)
+		}
+		for name, exp := range tc.exp {
+			buf, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(name)))
+			if err != nil {
+				t.Errorf("%s: %s", tc.opts.Generator, err)
+				continue
+			}
+			if !strings.HasPrefix(string(buf), exp) {
+				t.Errorf("%s: %s: expected prefix %q, got:\n%s", tc.opts.Generator, name, exp, buf)
+			}
+		}
+	}
+
+	if err := Site(root, t.TempDir(), SiteOptions{Generator: "mkdocs", FrontMatter: "toml"}, &Config{}); err == nil {
+		t.Error("expected an error for TOML front matter with mkdocs")
+	}
+	if err := Site(root, t.TempDir(), SiteOptions{Generator: "hugo"}, &Config{Format: "html"}); err == nil {
+		t.Error("expected an error for a site in HTML")
+	}
+}
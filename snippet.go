@@ -0,0 +1,359 @@
+package godoc2md
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// NoCheckMarker opts a code block out of CheckSnippets. It can be put anywhere in the code, e.g. in a
+// comment, or, in markdown, in an HTML comment on the line before the fence: <!-- godoc2md:nocheck -->.
+const NoCheckMarker = "godoc2md:nocheck"
+
+// snippet is a Go code block found in a doc comment or README.md.
+type snippet struct {
+	file string
+	line int // line of the first line of code in file
+	code string
+}
+
+// CheckSnippets type checks the Go code blocks in the doc comments of the package in path, the indented
+// blocks that are rendered as Go, and the ```go blocks in its README.md. Each snippet is checked on its
+// own, as declarations or else as the statements of a function, in a file that imports the package
+// and any standard library package it refers to. Unused variables and imports are not reported. For
+// each snippet that doesn't type check the first error is returned, with the position of the error in
+// the comment or README.md. Snippets containing NoCheckMarker are skipped, as are the code blocks of
+// the documentation godoc2md generated in README.md, when it has markers or the import line of the
+// package.
+func CheckSnippets(path string, config *Config) ([]LintIssue, error) {
+	ctxt, err := packageContext(path, config)
+	if err != nil {
+		return nil, err
+	}
+	bpkg, err := ctxt.ImportDir(path, 0)
+	if err != nil {
+		return nil, err
+	}
+	imp := config.Import
+	if root, mod := moduleRoot(path); root != "" {
+		abs, _ := filepath.Abs(path)
+		rel, _ := filepath.Rel(root, abs)
+		imp = filepath.ToSlash(filepath.Join(mod, rel))
+	}
+
+	var snippets []snippet
+	fset := token.NewFileSet()
+	for _, name := range append(bpkg.GoFiles, bpkg.CgoFiles...) {
+		file := filepath.Join(path, name)
+		f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		for _, cg := range docComments(f) {
+			snippets = append(snippets, commentSnippets(fset, cg, config)...)
+		}
+	}
+	if buf, err := os.ReadFile(filepath.Join(path, "README.md")); err == nil {
+		// The README.md may contain the documentation godoc2md generates, whose code blocks are
+		// declarations without bodies or come from the comments checked above: skip those.
+		generated := map[string]bool{}
+		if HasMarkers(buf) || bytes.Contains(buf, []byte("`import \""+imp+"\"`")) {
+			gen := *config
+			gen.Since, gen.VerifyExamples = false, false
+			doc := &bytes.Buffer{}
+			if err := Transform(doc, path, &gen); err == nil {
+				for _, s := range markdownSnippets("", doc.Bytes()) {
+					generated[s.code] = true
+				}
+			}
+		}
+		for _, s := range markdownSnippets(filepath.Join(path, "README.md"), buf) {
+			if !generated[s.code] {
+				snippets = append(snippets, s)
+			}
+		}
+	}
+
+	var issues []LintIssue
+	imports := newSourceImporter(path, fset)
+	for _, s := range snippets {
+		if strings.Contains(s.code, NoCheckMarker) {
+			continue
+		}
+		if issue := checkSnippet(fset, imports, path, s, bpkg, imp); issue != nil {
+			issues = append(issues, *issue)
+		}
+	}
+	return issues, nil
+}
+
+// docComments returns the doc comments in f: of the package, declarations, specs and fields.
+func docComments(f *ast.File) []*ast.CommentGroup {
+	var docs []*ast.CommentGroup
+	ast.Inspect(f, func(n ast.Node) bool {
+		var doc *ast.CommentGroup
+		switch n := n.(type) {
+		case *ast.File:
+			doc = n.Doc
+		case *ast.GenDecl:
+			doc = n.Doc
+		case *ast.FuncDecl:
+			doc = n.Doc
+		case *ast.TypeSpec:
+			doc = n.Doc
+		case *ast.ValueSpec:
+			doc = n.Doc
+		case *ast.Field:
+			doc = n.Doc
+		}
+		if doc != nil {
+			docs = append(docs, doc)
+		}
+		return true
+	})
+	return docs
+}
+
+// commentSnippets returns the code blocks in the comment cg that are rendered as Go.
+func commentSnippets(fset *token.FileSet, cg *ast.CommentGroup, config *Config) []snippet {
+	// Keep a line of text for each line of the comment, unlike cg.Text, so the line numbers of the
+	// code blocks can be found.
+	var lines []string
+	for _, c := range cg.List {
+		text := c.Text
+		switch text[1] {
+		case '/':
+			text = strings.TrimPrefix(text[2:], " ")
+		case '*':
+			text = text[2 : len(text)-2]
+		}
+		lines = append(lines, strings.Split(text, "\n")...)
+	}
+	start := fset.Position(cg.Pos())
+
+	var snippets []snippet
+	for _, b := range blocks(strings.Join(lines, "\n")) {
+		if b.op != opPre || config.codeLang(b.lines) != "go" {
+			continue
+		}
+		snippets = append(snippets, snippet{file: start.Filename, line: start.Line + b.line, code: strings.Join(b.lines, "")})
+	}
+	return snippets
+}
+
+var (
+	goFenceRx = regexp.MustCompile("^(```+|~~~+) *go\\b")
+	noCheckRx = regexp.MustCompile(`^<!--\s*` + NoCheckMarker + `\s*-->$`)
+)
+
+// markdownSnippets returns the fenced Go code blocks in the markdown in buf, read from file.
+func markdownSnippets(file string, buf []byte) []snippet {
+	var (
+		snippets []snippet
+		cur      *snippet
+		fence    string
+		nocheck  bool
+		prev     string // previous non-blank line
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case cur != nil && strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "":
+			if !nocheck {
+				snippets = append(snippets, *cur)
+			}
+			cur = nil
+		case cur != nil:
+			cur.code += line + "\n"
+		case goFenceRx.MatchString(trimmed):
+			fence = goFenceRx.FindStringSubmatch(trimmed)[1]
+			cur = &snippet{file: file, line: n + 1}
+			nocheck = noCheckRx.MatchString(prev)
+		}
+		if trimmed != "" {
+			prev = trimmed
+		}
+	}
+	return snippets
+}
+
+// checkSnippet type checks the snippet s, in a file in dir that imports the package bpkg with import
+// path imp. It returns the first error, or nil.
+func checkSnippet(fset *token.FileSet, imports types.Importer, dir string, s snippet, bpkg *build.Package, imp string) *LintIssue {
+	file := filepath.Join(dir, "godoc2md_snippet.go")
+	code := fmt.Sprintf("//line %s:%d\n%s", s.file, s.line, s.code)
+	// wraps returns the source of the file with the snippet, as declarations or as statements, with the
+	// import declaration decl.
+	wraps := []func(decl string) string{
+		func(decl string) string { return "package snippet\n\n" + decl + "\n" + code },
+		func(decl string) string { return "package snippet\n\n" + decl + "\nfunc _() {\n" + code + "\n}\n" },
+	}
+	if strings.HasPrefix(strings.TrimSpace(s.code), "package ") {
+		wraps = []func(string) string{func(string) string { return code }}
+	}
+
+	for _, wrap := range wraps {
+		f, err := parser.ParseFile(token.NewFileSet(), file, wrap(""), 0)
+		if err != nil {
+			continue
+		}
+		// add the imports the snippet needs, and parse again in fset
+		if f, err = parser.ParseFile(fset, file, wrap(snippetImports(f, bpkg, imp)), 0); err != nil {
+			break
+		}
+
+		var first *types.Error
+		conf := types.Config{
+			Importer: imports,
+			Error: func(err error) {
+				if terr, ok := err.(types.Error); ok && !terr.Soft && first == nil {
+					first = &terr
+				}
+			},
+		}
+		conf.Check("snippet", fset, []*ast.File{f}, nil)
+		if first == nil {
+			return nil
+		}
+		p := fset.Position(first.Pos)
+		return &LintIssue{Pos: fmt.Sprintf("%s:%d", p.Filename, p.Line), Message: "snippet doesn't compile: " + first.Msg}
+	}
+	return &LintIssue{Pos: fmt.Sprintf("%s:%d", s.file, s.line), Message: "snippet doesn't parse as Go"}
+}
+
+// snippetImports returns the import declaration for the packages referred to in f, but not imported by
+// it: the package bpkg, with import path imp, and standard library packages.
+func snippetImports(f *ast.File, bpkg *build.Package, imp string) string {
+	imported := map[string]bool{}
+	for _, spec := range f.Imports {
+		p, _ := strconv.Unquote(spec.Path.Value)
+		name := path.Base(p)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imported[name] = true
+	}
+	needed := map[string]string{}
+	std := stdPackages()
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		x, ok := sel.X.(*ast.Ident)
+		if !ok || imported[x.Name] {
+			return true
+		}
+		switch {
+		case x.Name == bpkg.Name && bpkg.Name != "main" && imp != "":
+			needed[x.Name] = imp
+		case std[x.Name] != "":
+			needed[x.Name] = std[x.Name]
+		}
+		return true
+	})
+	if len(needed) == 0 {
+		return ""
+	}
+	var specs []string
+	for name, p := range needed {
+		specs = append(specs, name+" "+strconv.Quote(p))
+	}
+	sort.Strings(specs)
+	return "import (\n" + strings.Join(specs, "\n") + "\n)\n"
+}
+
+var (
+	stdOnce sync.Once
+	std     map[string]string
+)
+
+// stdPackages returns the packages of the standard library by their name, when two packages have the
+// same name the one with the shortest import path is used: rand is math/rand.
+func stdPackages() map[string]string {
+	stdOnce.Do(func() {
+		std = map[string]string{}
+		out, err := exec.Command("go", "list", "std").Output()
+		if err != nil {
+			return
+		}
+		for _, p := range strings.Fields(string(out)) {
+			if isInternal(p) || strings.HasPrefix(p, "vendor/") {
+				continue
+			}
+			name := path.Base(p)
+			if cur, ok := std[name]; !ok || len(p) < len(cur) || (len(p) == len(cur) && p > cur) {
+				std[name] = p
+			}
+		}
+	})
+	return std
+}
+
+// sourceImporter imports packages by type checking them from source, like the "source" importer of
+// go/importer, but resolves import paths from the module of a directory instead of from the working
+// directory.
+type sourceImporter struct {
+	ctxt build.Context
+	fset *token.FileSet
+	pkgs map[string]*types.Package
+}
+
+// newSourceImporter returns an importer that resolves import paths from dir.
+func newSourceImporter(dir string, fset *token.FileSet) *sourceImporter {
+	ctxt := defaultContext()
+	ctxt.Dir, _ = filepath.Abs(dir)
+	return &sourceImporter{ctxt: ctxt, fset: fset, pkgs: map[string]*types.Package{}}
+}
+
+func (s *sourceImporter) Import(path string) (*types.Package, error) {
+	return s.ImportFrom(path, s.ctxt.Dir, 0)
+}
+
+// ImportFrom type checks the package with import path path, as imported from dir. Errors in the
+// imported package are ignored, as long as it can be parsed.
+func (s *sourceImporter) ImportFrom(path, dir string, _ types.ImportMode) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	bp, err := s.ctxt.Import(path, dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	if pkg, ok := s.pkgs[bp.ImportPath]; ok {
+		if pkg == nil {
+			return nil, fmt.Errorf("import cycle through %s", bp.ImportPath)
+		}
+		return pkg, nil
+	}
+	s.pkgs[bp.ImportPath] = nil // import in progress
+
+	var files []*ast.File
+	for _, name := range append(bp.GoFiles, bp.CgoFiles...) {
+		f, err := parser.ParseFile(s.fset, filepath.Join(bp.Dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	conf := types.Config{Importer: s, FakeImportC: true, Error: func(error) {}}
+	pkg, _ := conf.Check(bp.ImportPath, s.fset, files, nil)
+	s.pkgs[bp.ImportPath] = pkg
+	return pkg, nil
+}
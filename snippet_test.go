@@ -0,0 +1,70 @@
+package godoc2md
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheckSnippets(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/p\n\ngo 1.17\n",
+		"p.go": `// Package p greets.
+//
+//	fmt.Println(p.Hello())
+package p
+
+// Hello returns a greeting, don't use:
+//
+//	s := p.Goodbye()
+func Hello() string { return "hello" }
+
+// World returns the world.
+//
+//	// godoc2md:nocheck
+//	w := p.Planet()
+func World() string { return "world" }
+`,
+		"README.md": "# p\n\n```go\nfmt.Println(p.Hello())\n```\n\n``` go\nvar x int = \"x\"\n```\n\n<!-- godoc2md:nocheck -->\n```go\nx := y\n```\n",
+	}
+	writeFiles(t, dir, files)
+	issues, err := CheckSnippets(dir, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The messages come from go/types, only check how they start.
+	want := []string{"p.go:8", "README.md:8"}
+	got := []string{}
+	for _, i := range issues {
+		got = append(got, strings.TrimPrefix(i.Pos, dir+"/"))
+		if !strings.HasPrefix(i.Message, "snippet doesn't compile: ") {
+			t.Errorf("expected %s: snippet doesn't compile, got %q", i.Pos, i.Message)
+		}
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected issues (-want +got):\n%s", diff)
+	}
+
+	// The code blocks of the documentation injected in README.md are not checked.
+	doc := &bytes.Buffer{}
+	if err := Transform(doc, dir, &Config{}); err != nil {
+		t.Fatal(err)
+	}
+	readme, err := Inject([]byte("# p\n\n<!-- godoc2md:begin -->\n<!-- godoc2md:end -->\n"), doc.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/README.md", readme, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if issues, err = CheckSnippets(dir, &Config{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || !strings.HasSuffix(issues[0].Pos, "p.go:8") {
+		t.Errorf("expected only the issue in p.go:8, got %v", issues)
+	}
+}
@@ -0,0 +1,199 @@
+package godoc2md
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FunctionsPage is the file name of the page with the functions of a split package, see TransformSplit.
+const FunctionsPage = "functions.md"
+
+// TypePage returns the file name of the page of the type name in a split package, see TransformSplit.
+func TypePage(name string) string { return "type-" + name + ".md" }
+
+// TransformSplit is like Transform, but splits the documentation of the package in path over several
+// pages, for packages that are too large for a single one. The page index has the overview, the index,
+// the constants, variables and notes; FunctionsPage has the functions that don't belong to a type; and
+// each type gets a page, see TypePage, with its constants, variables, constructors and methods. Links
+// between the pages, including the doc links in comments, are rewritten to point to the right page. The
+// pages are returned by file name, a command only has an index page.
+func TransformSplit(path, index string, config *Config) (map[string][]byte, error) {
+	a, r, err := config.prepare()
+	if err != nil {
+		return nil, err
+	}
+	if r != nil {
+		return nil, errors.New("only markdown can be split")
+	}
+
+	fs, pres := presentation(config)
+	info, err := load(fs, pres, path, config)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := readTemplate(pres, fs, "package.txt", pkgTemplate, config, info)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, info); err != nil {
+		return nil, err
+	}
+
+	raw := normalize(buf.Bytes())
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return map[string][]byte{}, nil
+	}
+
+	page := map[string]string{} // page of the level 2 headings of the functions and types, by key
+	name := ""
+	if info.PDoc != nil {
+		name = info.PDoc.Name
+		for _, f := range info.PDoc.Funcs {
+			page[f.Name] = FunctionsPage
+		}
+		for _, t := range info.PDoc.Types {
+			page[t.Name] = TypePage(t.Name)
+		}
+	}
+	pages := splitPages(raw, index, page)
+	for file, lines := range pages {
+		if file == index {
+			continue
+		}
+		head := []string{"[Back to package " + escapeMd(name, ctxLink) + "](#pkg-index)", ""}
+		if file == FunctionsPage {
+			head = append(head, "# Functions {#pkg-functions}", "")
+		}
+		pages[file] = append(head, lines...)
+	}
+	return linkPages(pages, index, a, config.HeadingOffset), nil
+}
+
+// CheckSplit is like Check for a package split with TransformSplit: it returns the diff between the
+// pages in the directory path and the ones generated, index first. Pages of an earlier split that are
+// no longer generated, see OrphanedPages, show up as deleted.
+func CheckSplit(path, index string, config *Config) (string, error) {
+	pages, err := TransformSplit(path, index, config)
+	if err != nil {
+		return "", err
+	}
+	if len(pages) == 0 {
+		return "", nil
+	}
+	orphans, err := OrphanedPages(path, pages)
+	if err != nil {
+		return "", err
+	}
+	names := []string{}
+	for name := range pages {
+		if name != index {
+			names = append(names, name)
+		}
+	}
+	names = append(names, orphans...)
+	sort.Strings(names)
+
+	b := &strings.Builder{}
+	for _, name := range append([]string{index}, names...) {
+		file := filepath.Join(path, name)
+		old, err := os.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		b.WriteString(unifiedDiff(file, file+" (generated)", old, pages[name]))
+	}
+	return b.String(), nil
+}
+
+// OrphanedPages returns the pages in the directory dir that an earlier TransformSplit wrote but that
+// aren't in pages: FunctionsPage and the pages of types, see TypePage, that were removed since.
+func OrphanedPages(dir string, pages map[string][]byte) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, TypePage("*")))
+	if err != nil {
+		return nil, err
+	}
+	matches = append(matches, filepath.Join(dir, FunctionsPage))
+	var orphans []string
+	for _, m := range matches {
+		name := filepath.Base(m)
+		if _, ok := pages[name]; ok {
+			continue
+		}
+		if _, err := os.Stat(m); err == nil {
+			orphans = append(orphans, name)
+		}
+	}
+	sort.Strings(orphans)
+	return orphans, nil
+}
+
+// splitPages splits the lines of the markdown buf into pages: a level 2 heading whose key is in page
+// starts a section on that page, other level 2 headings, and the Subdirectories, go to index.
+func splitPages(buf []byte, index string, page map[string]string) map[string][]string {
+	pages := map[string][]string{}
+	file, fence := index, ""
+	for _, line := range strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n") {
+		if fence = fenced(line, fence); fence == "" {
+			level, key := headingLevel(line, ""), ""
+			if m := headingIDRx.FindStringSubmatch(line); m != nil {
+				key = m[1]
+			}
+			switch {
+			case level == 2:
+				file = index
+				if p, ok := page[key]; ok {
+					file = p
+				}
+			case level > 2 && key == "pkg-subdirectories":
+				file = index
+			}
+		}
+		pages[file] = append(pages[file], line)
+	}
+	return pages
+}
+
+// linkPages turns the heading keys of the pages into anchors of style a and rewrites the local links:
+// a key that isn't on the page itself is looked up on index, and then on the other pages in order. The
+// headings are shifted so each page other than index starts at level 1, and then by offset.
+func linkPages(pages map[string][]string, index string, a *Anchor, offset int) map[string][]byte {
+	files := []string{index}
+	for file := range pages {
+		if file != index {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files[1:])
+
+	keys := map[string]headingKeys{}
+	for _, file := range files {
+		keys[file] = headingAnchors(pages[file], a)
+	}
+	out := map[string][]byte{}
+	for _, file := range files {
+		lines := pages[file]
+		rewriteLinks(lines, func(key string, i int) (string, bool) {
+			if anchor, ok := keys[file].resolve(key, i); ok {
+				return "#" + anchor, true
+			}
+			for _, other := range files {
+				if occs := keys[other][key]; len(occs) > 0 {
+					return other + "#" + occs[0].anchor, true
+				}
+			}
+			return "", false
+		})
+		buf := normalize([]byte(strings.Join(lines, "\n")))
+		n := offset
+		if file != index {
+			n += 1 - minHeadingLevel(buf)
+		}
+		out[file] = shiftHeadings(buf, n)
+	}
+	return out
+}
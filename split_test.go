@@ -0,0 +1,113 @@
+package godoc2md
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTransformSplit(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "foo")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	src := `// Package foo is the package, see [Client].
+package foo
+
+// Max is the maximum, see [Client.Do].
+const Max = 1
+
+// Client is a client, made by [New].
+type Client struct{}
+
+// New returns a [Client] that does at most [Max] things.
+func New() *Client { return nil }
+
+// Do does it, like [Run].
+func (c *Client) Do() {}
+
+// Run runs with a [Client].
+func Run(c *Client) {}
+`
+	if err := os.WriteFile(filepath.Join(dir, "foo.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pages, err := TransformSplit(dir, "README.md", &Config{Import: "example.com/foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string][]string{
+		"README.md": {
+			"Package foo is the package, see [Client](type-Client.md#Client).",
+			"* [func Run(c \\*Client)](functions.md#Run)",
+			"* [type Client](type-Client.md#Client)",
+			"  * [func (c \\*Client) Do()](type-Client.md#Client.Do)",
+			"Max is the maximum, see [Client.Do](type-Client.md#Client.Do).",
+		},
+		"type-Client.md": {
+			"[Back to package foo](README.md#pkg-index)\n\n# type [Client]",
+			"Client is a client, made by [New](#New).",
+			"## func [New]",
+			"at most [Max](README.md#pkg-constants) things.",
+			"Do does it, like [Run](functions.md#Run).",
+		},
+		"functions.md": {
+			"# Functions {#pkg-functions}\n\n## func [Run]",
+			"Run runs with a [Client](type-Client.md#Client).",
+		},
+	}
+	if len(pages) != len(tests) {
+		t.Errorf("expected %d pages, got %d", len(tests), len(pages))
+	}
+	for file, exp := range tests {
+		for _, s := range exp {
+			if !strings.Contains(string(pages[file]), s) {
+				t.Errorf("%s: expected %q in output, got:\n%s", file, s, pages[file])
+			}
+		}
+	}
+	if strings.Contains(string(pages["README.md"]), "Run runs") {
+		t.Errorf("expected functions on their own page, got:\n%s", pages["README.md"])
+	}
+
+	pages, err = TransformSplit(dir, "README.md", &Config{Import: "example.com/foo", Flavor: "github"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := "made by [New](#func-new)."; !strings.Contains(string(pages["type-Client.md"]), s) {
+		t.Errorf("expected %q in output, got:\n%s", s, pages["type-Client.md"])
+	}
+	if s := "see [Client](type-Client.md#type-client)."; !strings.Contains(string(pages["README.md"]), s) {
+		t.Errorf("expected %q in output, got:\n%s", s, pages["README.md"])
+	}
+
+	config := &Config{Import: "example.com/foo"}
+	if pages, err = TransformSplit(dir, "README.md", config); err != nil {
+		t.Fatal(err)
+	}
+	pages["type-Old.md"] = []byte("# type Old\n") // a type that was removed
+	for name, page := range pages {
+		if err := os.WriteFile(filepath.Join(dir, name), page, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	delete(pages, "type-Old.md")
+	orphans, err := OrphanedPages(dir, pages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"type-Old.md"}; !cmp.Equal(orphans, exp) {
+		t.Errorf("expected orphans %v, got %v", exp, orphans)
+	}
+	diff, err := CheckSplit(dir, "README.md", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "type-Old.md (generated)\n@@ -1 +0,0 @@\n-# type Old\n"; !strings.HasSuffix(diff, exp) || strings.Count(diff, "+++ ") != 1 {
+		t.Errorf("expected only type-Old.md to be stale, got:\n%s", diff)
+	}
+}
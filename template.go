@@ -1,36 +1,72 @@
 package godoc2md
 
+// indexTemplate is the list of the Index, used in pkgTemplate and refTemplate. Index_url returns the
+// target of the links, synopsis_md the synopsis that follows them, if any, and index_anchor the anchor
+// put in front of them, if any.
+var indexTemplate = `{{if .Consts}}
+* [Constants](#pkg-constants){{end}}{{if .Vars}}
+* [Variables](#pkg-variables){{end}}{{- range .Funcs -}}{{$name_html := html .Name}}
+* {{index_anchor $name_html (printf "func %s" (bitscape .Name))}}[{{node $ .Decl | sanitize | bitscape}}]({{index_url $ .Decl $name_html}}){{since .Name}}{{platforms .Name}}{{with synopsis_md .Doc}} - {{.}}{{end}}{{- end}}{{- range .Types}}{{$tname_html := html .Name}}
+* {{index_anchor $tname_html (printf "type %s" (bitscape .Name))}}[type {{bitscape .Name}}]({{index_url $ .Decl $tname_html}}){{since .Name}}{{platforms .Name}}{{with synopsis_md .Doc}} - {{.}}{{end}}{{if toc 2}}{{- range .Funcs}}{{$name_html := html .Name}}
+  * {{index_anchor $name_html (printf "func %s" (bitscape .Name))}}[{{node $ .Decl | sanitize | bitscape}}]({{index_url $ .Decl $name_html}}){{since .Name}}{{platforms .Name}}{{with synopsis_md .Doc}} - {{.}}{{end}}{{- end}}{{- range .Methods}}{{$name_html := html .Name}}
+  * {{index_anchor (printf "%s.%s" $tname_html $name_html) (printf "func (%s) %s" (md .Recv) (bitscape .Name))}}[{{node $ .Decl | sanitize | bitscape}}]({{index_url $ .Decl (printf "%s.%s" $tname_html $name_html)}}){{since (printf "%s.%s" $tname_html .Name)}}{{platforms (printf "%s.%s" $tname_html .Name)}}{{with synopsis_md .Doc}} - {{.}}{{end}}{{- end}}{{- end}}{{- end}}`
+
+// refTemplate is the quick reference of a package, see Config.QuickRef.
+var refTemplate = `{{with .PDoc}}
+{{if $.IsMain}}
+> {{ base .ImportPath }}
+
+{{synopsis_md .Doc}}
+{{else}}
+# {{ .Name }}
+` + "`" + `import "{{.ImportPath}}"` + "`" + `
+
+{{synopsis_md .Doc}}
+
+## Index {#pkg-index}
+` + indexTemplate + `
+{{with .Consts}}
+## Constants {#pkg-constants}
+{{range .}}
+* [const {{range $i, $n := .Names}}{{if $i}}, {{end}}{{bitscape $n}}{{end}}]({{posLink_url $ .Decl}}){{with synopsis_md .Doc}} - {{.}}{{end}}{{end}}
+{{end}}
+{{with .Vars}}
+## Variables {#pkg-variables}
+{{range .}}
+* [var {{range $i, $n := .Names}}{{if $i}}, {{end}}{{bitscape $n}}{{end}}]({{posLink_url $ .Decl}}){{with synopsis_md .Doc}} - {{.}}{{end}}{{end}}
+{{end}}
+{{end}}
+{{end}}
+`
+
 var pkgTemplate = `{{with .PDoc}}
 {{if $.IsMain}}
 > {{ base .ImportPath }}
 {{comment_md .Doc}}
+{{usage}}
 {{else}}
 # {{ .Name }}
 ` + "`" + `import "{{.ImportPath}}"` + "`" + `
-
+{{if toc 1}}
 * [Overview](#pkg-overview)
 * [Index](#pkg-index){{if $.Examples}}
 * [Examples](#pkg-examples){{- end}}{{if $.Dirs}}
 * [Subdirectories](#pkg-subdirectories){{- end}}
-
+{{end}}
 ## Overview {#pkg-overview}
 {{comment_md .Doc}}
-{{example_html $ ""}}
+{{class_diagram}}
+{{example_md ""}}
 
-## Index{{if .Consts}} {#pkg-index}
-* [Constants](#pkg-constants){{end}}{{if .Vars}}
-* [Variables](#pkg-variables){{end}}{{- range .Funcs -}}{{$name_html := html .Name}}
-* [{{node_html $ .Decl false | sanitize}}](#{{$name_html}}){{- end}}{{- range .Types}}{{$tname_html := html .Name}}
-* [type {{$tname_html}}](#{{$tname_html}}){{- range .Funcs}}{{$name_html := html .Name}}
-  * [{{node_html $ .Decl false | sanitize}}](#{{$name_html}}){{- end}}{{- range .Methods}}{{$name_html := html .Name}}
-  * [{{node_html $ .Decl false | sanitize}}](#{{$tname_html}}.{{$name_html}}){{- end}}{{- end}}{{- if $.Notes}}{{- range $marker, $item := $.Notes}}
+## Index {#pkg-index}{{if toc 1}}{{details_begin "Index"}}` + indexTemplate + `{{- if $.Notes}}{{- range $marker, $item := $.Notes}}
 * [{{noteTitle $marker | html}}s](#pkg-note-{{$marker}}){{end}}{{end}}
+{{details_end}}{{end}}
 {{if $.Examples}}
 #### Examples {#pkg-examples} {{- range $.Examples}}
 * [{{example_name .Name}}](#example_{{.Name}}){{- end}}{{- end}}
 {{with .Filenames}}
 #### Package files {#pkg-files}
-{{range .}}[{{.|filename|html}}]({{.|srcLink|html}}) {{end}}
+{{range .}}[{{.|filename|bitscape}}]({{.|srcLink|html}}) {{end}}
 {{end}}
 
 {{with .Consts}}## Constants {#pkg-constants}
@@ -40,12 +76,12 @@ var pkgTemplate = `{{with .PDoc}}
 {{range .}}{{node $ .Decl | pre}}
 {{comment_md .Doc}}{{end}}{{end}}
 
-{{range .Funcs}}{{$name_html := html .Name}}## func [{{$name_html}}]({{posLink_url $ .Decl}}) {#{{$name_html}}}
+{{range .Funcs}}{{$name_html := html .Name}}## func [{{bitscape .Name}}]({{posLink_url $ .Decl}}){{since .Name}}{{platforms .Name}} {#{{$name_html}}}
 {{node $ .Decl | pre}}
 {{comment_md .Doc}}
-{{example_html $ .Name}}
+{{example_md .Name}}
 {{callgraph_html $ "" .Name}}{{end}}
-{{range .Types}}{{$tname := .Name}}{{$tname_html := html .Name}}## type [{{$tname_html}}]({{posLink_url $ .Decl}}) {#{{$tname_html}}}
+{{range .Types}}{{$tname := .Name}}{{$tname_html := html .Name}}## type [{{bitscape .Name}}]({{posLink_url $ .Decl}}){{since .Name}}{{platforms .Name}} {#{{$tname_html}}}
 {{node $ .Decl | pre}}
 {{comment_md .Doc}}{{range .Consts}}
 {{node $ .Decl | pre }}
@@ -53,26 +89,26 @@ var pkgTemplate = `{{with .PDoc}}
 {{node $ .Decl | pre }}
 {{comment_md .Doc}}{{end}}
 
-{{example_html $ $tname}}
+{{example_md $tname}}
 {{implements_html $ $tname}}
 {{methodset_html $ $tname}}
 
-{{range .Funcs}}{{$name_html := html .Name}}### func [{{$name_html}}]({{posLink_url $ .Decl}}) {#{{$name_html}}}
+{{range .Funcs}}{{$name_html := html .Name}}### func [{{bitscape .Name}}]({{posLink_url $ .Decl}}){{since .Name}}{{platforms .Name}} {#{{$name_html}}}
 {{node $ .Decl | pre}}
 {{comment_md .Doc}}
-{{example_html $ .Name}}{{end}}
+{{example_md .Name}}{{end}}
 {{callgraph_html $ "" .Name}}
 
-{{range .Methods}}{{$name_html := html .Name}}### func ({{md .Recv}}) [{{$name_html}}]({{posLink_url $ .Decl}}) {#{{$tname_html}}.{{$name_html}}}
+{{range .Methods}}{{$name_html := html .Name}}### func ({{md .Recv}}) [{{bitscape .Name}}]({{posLink_url $ .Decl}}){{since (printf "%s.%s" $tname .Name)}}{{platforms (printf "%s.%s" $tname .Name)}} {#{{$tname_html}}.{{$name_html}}}
 {{node $ .Decl | pre}}
 {{comment_md .Doc}}
-{{$name := printf "%s_%s" $tname .Name}}{{example_html $ $name}}
+{{$name := printf "%s_%s" $tname .Name}}{{example_md $name}}
 {{callgraph_html $ .Recv .Name}}
 {{end}}{{end}}{{end}}
 
 {{with $.Notes}}
 {{range $marker, $content := .}}
-## <a name="pkg-note-{{$marker}}">{{noteTitle $marker | html}}s
+## {{noteTitle $marker | html}}s {#pkg-note-{{$marker}}}
 <ul style="list-style: none; padding: 0;">
 {{range .}}
 <li><a href="{{posLink_url $ .}}">&#x261e;</a> {{html .Body}}</li>